@@ -0,0 +1,116 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code 错误码，各传输层据此映射为HTTP状态码、gRPC状态码或JSON-RPC错误码
+type Code string
+
+const (
+	InvalidArgument      Code = "INVALID_ARGUMENT"       // 请求参数错误
+	DivideByZero         Code = "DIVIDE_BY_ZERO"         // 除数为0
+	OutOfRange           Code = "OUT_OF_RANGE"           // 结果溢出或超出数值范围
+	NotFound             Code = "NOT_FOUND"              // 运算或路由不存在
+	MethodNotAllowed     Code = "METHOD_NOT_ALLOWED"     // 请求方法不支持
+	NotAcceptable        Code = "NOT_ACCEPTABLE"         // 无法返回Accept要求的格式
+	UnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE" // 不支持请求体的格式
+	Unauthenticated      Code = "UNAUTHENTICATED"        // 身份认证失败
+	PermissionDenied     Code = "PERMISSION_DENIED"      // 授权失败
+	RateLimited          Code = "RATE_LIMITED"           // 触发限流
+	Unavailable          Code = "UNAVAILABLE"            // 服务过载，暂时无法处理
+	Internal             Code = "INTERNAL"               // 其他未分类的错误
+)
+
+// Coder 可以给出错误码的错误
+type Coder interface {
+	ErrorCode() Code
+}
+
+// Detailer 可以给出附加信息的错误，附加信息放在响应的details中
+type Detailer interface {
+	ErrorDetails() interface{}
+}
+
+// Error 带错误码的错误，同时也是JSON错误响应中error字段的结构
+type Error struct {
+	Code    Code        `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+
+	err error
+}
+
+// New 创建带错误码的错误，通常用于定义包级别的错误变量
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap 为已有错误指定错误码，errors.Is/As仍可匹配原错误
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Message: err.Error(), err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// CodeOf 返回错误链中第一个错误码，没有错误码的错误视为Internal
+func CodeOf(err error) Code {
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.ErrorCode()
+	}
+	return Internal
+}
+
+// internalMessage Internal错误返回给客户端的信息，原错误可能包含内部细节，只记录在日志中
+const internalMessage = "internal error"
+
+// From 将任意错误转换为统一的错误结构，Message取最外层错误的信息，
+// Internal错误统一使用通用信息，调用方负责记录原错误
+func From(err error) *Error {
+	e := &Error{Code: CodeOf(err), Message: err.Error(), err: err}
+	if e.Code == Internal {
+		e.Message = internalMessage
+	}
+	var detailer Detailer
+	if errors.As(err, &detailer) {
+		e.Details = detailer.ErrorDetails()
+	}
+	return e
+}
+
+// HTTPStatus 错误码对应的HTTP状态码
+func HTTPStatus(code Code) int {
+	switch code {
+	case InvalidArgument, DivideByZero, OutOfRange:
+		return http.StatusBadRequest
+	case NotFound:
+		return http.StatusNotFound
+	case MethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case NotAcceptable:
+		return http.StatusNotAcceptable
+	case UnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case Unauthenticated:
+		return http.StatusUnauthorized
+	case PermissionDenied:
+		return http.StatusForbidden
+	case RateLimited:
+		return http.StatusTooManyRequests
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,367 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"learn/apperrors"
+	"learn/endpoints"
+	"learn/services"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/lb"
+	"github.com/go-kit/log"
+)
+
+// 客户端默认参数
+const (
+	DefaultTimeout = time.Second
+	DefaultRetries = 3
+)
+
+type options struct {
+	timeout    time.Duration
+	retries    int
+	httpClient *http.Client
+	breaker    hystrix.CommandConfig
+	name, pwd  string
+}
+
+// Option 客户端配置项
+type Option func(*options)
+
+// WithTimeout 单次请求的超时时间，同时作为熔断器的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithRetries 请求失败时的最大尝试次数，客户端错误（4xx）不重试
+func WithRetries(retries int) Option {
+	return func(o *options) { o.retries = retries }
+}
+
+// WithHTTPClient 使用自定义的http.Client
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) { o.httpClient = client }
+}
+
+// WithBreaker 熔断器配置，Timeout为0时使用请求超时时间
+func WithBreaker(config hystrix.CommandConfig) Option {
+	return func(o *options) { o.breaker = config }
+}
+
+// WithCredentials 设置登录凭证，需要认证的请求会自动登录，token过期后自动刷新或重新登录
+func WithCredentials(name, pwd string) Option {
+	return func(o *options) { o.name, o.pwd = name, pwd }
+}
+
+// Client 算术服务客户端，实现services.Service，可在多个服务实例间负载均衡
+// services.Service中不返回错误的方法（Add、Subtract、Multiply）调用失败时返回0，需要错误信息时使用Calculate
+type Client struct {
+	endpoints  endpoints.ArithmeticEndpoints
+	endpointer []*sd.DefaultEndpointer
+	breakers   *breakerSettings
+	timeout    time.Duration
+	tokens     *tokenStore
+	// callTimeout 未指定context的方法的超时时间，包含重试和登录
+	callTimeout time.Duration
+}
+
+var _ services.Service = (*Client)(nil)
+
+// NewClient 基于服务发现创建客户端，instancer提供服务实例地址，如consul.NewInstancer
+func NewClient(instancer sd.Instancer, logger log.Logger, opts ...Option) *Client {
+	o := options{timeout: DefaultTimeout, retries: DefaultRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.httpClient == nil {
+		o.httpClient = &http.Client{Timeout: o.timeout}
+	}
+	if o.retries < 1 {
+		o.retries = 1
+	}
+
+	c := &Client{
+		breakers:    &breakerSettings{config: o.breaker},
+		timeout:     o.timeout,
+		callTimeout: 2 * time.Duration(o.retries) * o.timeout,
+	}
+	makeEndpoint := func(method, path string, codec httpCodec) endpoint.Endpoint {
+		endpointer := sd.NewEndpointer(instancer, makeHTTPFactory(method, path, codec, o, c.breakers), logger)
+		c.endpointer = append(c.endpointer, endpointer)
+		return retry(o.retries, time.Duration(o.retries)*o.timeout, lb.NewRoundRobin(endpointer))
+	}
+	c.endpoints = endpoints.ArithmeticEndpoints{
+		ArithmeticEndpoint:  makeEndpoint("POST", "/v1/calculate", calculateCodec),
+		OperationsEndpoint:  makeEndpoint("GET", "/v1/operations", operationsCodec),
+		EvaluateEndpoint:    makeEndpoint("POST", "/evaluate", evaluateCodec),
+		BatchEndpoint:       makeEndpoint("POST", "/calculate/batch", batchCodec),
+		HealthCheckEndpoint: makeEndpoint("GET", "/health", healthCodec),
+		AuthEndpoint:        makeEndpoint("POST", "/login", authCodec),
+		RefreshEndpoint:     makeEndpoint("POST", "/refresh", refreshCodec),
+		LogoutEndpoint:      makeEndpoint("POST", "/logout", logoutCodec),
+	}
+	c.tokens = &tokenStore{
+		name:    o.name,
+		pwd:     o.pwd,
+		login:   c.endpoints.AuthEndpoint,
+		refresh: c.endpoints.RefreshEndpoint,
+	}
+	c.SetBreaker(o.breaker)
+	return c
+}
+
+// SetBreaker 修改所有熔断器的配置，Timeout为0时使用请求超时时间
+// MaxConcurrentRequests只对之后新建的熔断器生效，见breakerSettings
+func (c *Client) SetBreaker(config hystrix.CommandConfig) {
+	if config.Timeout == 0 {
+		config.Timeout = int(c.timeout / time.Millisecond)
+	}
+	c.breakers.update(config)
+}
+
+// NewHTTPClient 创建访问单个服务实例的客户端，instance为服务地址，如localhost:9000
+func NewHTTPClient(instance string, opts ...Option) *Client {
+	return NewClient(sd.FixedInstancer{instance}, log.NewNopLogger(), opts...)
+}
+
+// Close 停止接收服务实例变化
+func (c *Client) Close() error {
+	for _, endpointer := range c.endpointer {
+		endpointer.Close()
+	}
+	return nil
+}
+
+// retry 为负载均衡器增加重试，客户端错误和达到次数上限时停止，返回最后一次的错误
+func retry(max int, timeout time.Duration, balancer lb.Balancer) endpoint.Endpoint {
+	next := lb.RetryWithCallback(timeout, balancer, func(n int, err error) (bool, error) {
+		return n < max && !isClientError(err), nil
+	})
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := next(ctx, request)
+		var retryErr lb.RetryError
+		if errors.As(err, &retryErr) && retryErr.Final != nil {
+			err = retryErr.Final
+		}
+		return response, err
+	}
+}
+
+// isClientError 服务端返回的4xx错误，重试不会改变结果，也不计入熔断
+func isClientError(err error) bool {
+	var appErr *apperrors.Error
+	return errors.As(err, &appErr) && apperrors.HTTPStatus(appErr.Code) < http.StatusInternalServerError
+}
+
+// call 调用需要认证的Endpoint，token失效时重新获取token后再试一次
+func (c *Client) call(ctx context.Context, e endpoint.Endpoint, request interface{}) (interface{}, error) {
+	token, err := c.tokens.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := e(withToken(ctx, token), request)
+	if apperrors.CodeOf(err) == apperrors.Unauthenticated && c.tokens.invalidate(token) {
+		if token, err = c.tokens.accessToken(ctx); err != nil {
+			return nil, err
+		}
+		response, err = e(withToken(ctx, token), request)
+	}
+	return response, err
+}
+
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.callTimeout)
+}
+
+// Calculate 执行运算请求，Mode为空时按int模式计算
+func (c *Client) Calculate(ctx context.Context, req endpoints.ArithmeticRequest) (endpoints.ArithmeticResponse, error) {
+	response, err := c.call(ctx, c.endpoints.ArithmeticEndpoint, req)
+	if err != nil {
+		return endpoints.ArithmeticResponse{}, err
+	}
+	return response.(endpoints.ArithmeticResponse), nil
+}
+
+// Batch 批量执行运算请求，单个条目的错误放在对应结果的Error中
+func (c *Client) Batch(ctx context.Context, req endpoints.BatchRequest) (endpoints.BatchResponse, error) {
+	response, err := c.call(ctx, c.endpoints.BatchEndpoint, req)
+	if err != nil {
+		return endpoints.BatchResponse{}, err
+	}
+	return response.(endpoints.BatchResponse), nil
+}
+
+// Operations 查询服务支持的运算
+func (c *Client) Operations(ctx context.Context) ([]endpoints.Operation, error) {
+	response, err := c.endpoints.OperationsEndpoint(ctx, endpoints.OperationsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return response.(endpoints.OperationsResponse).Operations, nil
+}
+
+// calculate 按mode计算，b为空时为一元运算
+func (c *Client) calculate(op, mode string, a, b string) (string, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	res, err := c.Calculate(ctx, endpoints.ArithmeticRequest{RequestType: op, Mode: mode, A: json.Number(a), B: json.Number(b)})
+	if err != nil {
+		return "", err
+	}
+	if mode == services.ModeRat {
+		return res.Rational, nil
+	}
+	return res.Result.String(), nil
+}
+
+func (c *Client) calculateInt(op string, operands ...int) (int, error) {
+	a, b := strconv.Itoa(operands[0]), ""
+	if len(operands) > 1 {
+		b = strconv.Itoa(operands[1])
+	}
+	res, err := c.calculate(op, services.ModeInt, a, b)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(res)
+}
+
+func (c *Client) Add(a, b int) int {
+	res, _ := c.calculateInt(services.OpAdd, a, b)
+	return res
+}
+
+func (c *Client) Subtract(a, b int) int {
+	res, _ := c.calculateInt(services.OpSubtract, a, b)
+	return res
+}
+
+func (c *Client) Multiply(a, b int) int {
+	res, _ := c.calculateInt(services.OpMultiply, a, b)
+	return res
+}
+
+func (c *Client) Divide(a, b int) (int, error) {
+	return c.calculateInt(services.OpDivide, a, b)
+}
+
+func (c *Client) Mod(a, b int) (int, error) {
+	return c.calculateInt(services.OpMod, a, b)
+}
+
+func (c *Client) Pow(a, b int) (int, error) {
+	return c.calculateInt(services.OpPow, a, b)
+}
+
+func (c *Client) Sqrt(a int) (float64, error) {
+	res, err := c.calculate(services.OpSqrt, services.ModeInt, strconv.Itoa(a), "")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(res, 64)
+}
+
+func (c *Client) Gcd(a, b int) (int, error) {
+	return c.calculateInt(services.OpGcd, a, b)
+}
+
+func (c *Client) Lcm(a, b int) (int, error) {
+	return c.calculateInt(services.OpLcm, a, b)
+}
+
+func (c *Client) Factorial(n int) (int, error) {
+	return c.calculateInt(services.OpFactorial, n)
+}
+
+func (c *Client) CalculateInt64(op string, a, b int64) (int64, error) {
+	res, err := c.calculate(op, services.ModeInt64, strconv.FormatInt(a, 10), strconv.FormatInt(b, 10))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(res, 10, 64)
+}
+
+func (c *Client) CalculateFloat64(op string, a, b float64) (float64, error) {
+	res, err := c.calculate(op, services.ModeFloat64, strconv.FormatFloat(a, 'g', -1, 64), strconv.FormatFloat(b, 'g', -1, 64))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(res, 64)
+}
+
+func (c *Client) CalculateRat(op string, a, b *big.Rat) (*big.Rat, error) {
+	if a == nil || b == nil {
+		return nil, services.ErrNilOperand
+	}
+	res, err := c.calculate(op, services.ModeRat, a.RatString(), b.RatString())
+	if err != nil {
+		return nil, err
+	}
+	r, ok := new(big.Rat).SetString(res)
+	if !ok {
+		return nil, apperrors.New(apperrors.Internal, "invalid rational result "+strconv.Quote(res))
+	}
+	return r, nil
+}
+
+// Evaluate 由服务端计算表达式并按token授权，check只能在本地计算时使用
+func (c *Client) Evaluate(expr string, vars map[string]float64, check services.ExprCheck) (float64, error) {
+	if check != nil {
+		return 0, apperrors.New(apperrors.InvalidArgument, "clients: per-operation checks are not supported remotely")
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	response, err := c.call(ctx, c.endpoints.EvaluateEndpoint, endpoints.EvaluateRequest{Expr: expr, Vars: vars})
+	if err != nil {
+		return 0, err
+	}
+	return response.(endpoints.EvaluateResponse).Result.Float64()
+}
+
+// Login 登录并保存token，之后的请求使用该token
+func (c *Client) Login(name, pwd string) (services.Token, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	return c.tokens.doLogin(ctx, name, pwd)
+}
+
+// Refresh 使用刷新token换取新的token并保存
+func (c *Client) Refresh(refreshToken string) (services.Token, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	return c.tokens.doRefresh(ctx, refreshToken)
+}
+
+// Logout 吊销token，同时吊销并清除客户端保存的token
+func (c *Client) Logout(token string) error {
+	ctx, cancel := c.context()
+	defer cancel()
+	current := c.tokens.current()
+	_, err := c.endpoints.LogoutEndpoint(withToken(ctx, current.AccessToken), endpoints.LogoutRequest{RefreshToken: token})
+	if err != nil {
+		return err
+	}
+	if token == current.AccessToken || token == current.RefreshToken {
+		c.tokens.set(services.Token{})
+	}
+	return nil
+}
+
+// HealthCheck 服务不可达时返回false
+func (c *Client) HealthCheck() bool {
+	ctx, cancel := c.context()
+	defer cancel()
+	response, err := c.endpoints.HealthCheckEndpoint(ctx, endpoints.HealthRequest{})
+	if err != nil {
+		return false
+	}
+	return response.(endpoints.HealthResponse).Status
+}
@@ -0,0 +1,120 @@
+package clients
+
+import (
+	"encoding/json"
+	"learn/apperrors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeError 按服务端的错误格式写出错误响应
+func writeError(w http.ResponseWriter, code apperrors.Code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apperrors.HTTPStatus(code))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			writeError(w, apperrors.Internal, "try again")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"result": 2, "mode": "int"})
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL, WithRetries(3), WithTimeout(time.Second))
+	defer c.Close()
+	res, err := c.Divide(4, 2)
+	if err != nil || res != 2 {
+		t.Fatalf("Divide = %d, %v, want 2", res, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("server called %d times, want 3", n)
+	}
+}
+
+func TestNoRetryClientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeError(w, apperrors.DivideByZero, "the divided can not be zero!")
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL, WithRetries(3), WithTimeout(time.Second))
+	defer c.Close()
+	_, err := c.Divide(1, 0)
+	if code := apperrors.CodeOf(err); code != apperrors.DivideByZero {
+		t.Fatalf("error = %v, want %s", err, apperrors.DivideByZero)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("server called %d times, want 1", n)
+	}
+}
+
+func TestReloginAfterUnauthenticated(t *testing.T) {
+	var logins, calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			var req struct {
+				Name string `json:"name"`
+				Pwd  string `json:"pwd"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Name != "name" || req.Pwd != "pwd" {
+				writeError(w, apperrors.Unauthenticated, "bad credentials")
+				return
+			}
+			// 第一次登录的token已被服务端吊销
+			token := "revoked"
+			if atomic.AddInt32(&logins, 1) > 1 {
+				token = "valid"
+			}
+			writeJSON(w, map[string]interface{}{"success": true, "token": token, "expires_in": 3600})
+		case "/v1/calculate":
+			atomic.AddInt32(&calls, 1)
+			if r.Header.Get("Authorization") != "Bearer valid" {
+				writeError(w, apperrors.Unauthenticated, "token is revoked")
+				return
+			}
+			writeJSON(w, map[string]interface{}{"result": 3, "mode": "int"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL, WithCredentials("name", "pwd"), WithTimeout(time.Second))
+	defer c.Close()
+	if res := c.Add(1, 2); res != 3 {
+		t.Fatalf("Add = %d, want 3", res)
+	}
+	if n := atomic.LoadInt32(&logins); n != 2 {
+		t.Errorf("logged in %d times, want 2", n)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("calculate called %d times, want 2", n)
+	}
+
+	// 新token继续使用，不再登录
+	if res := c.Add(1, 2); res != 3 {
+		t.Fatalf("Add = %d, want 3", res)
+	}
+	if n := atomic.LoadInt32(&logins); n != 2 {
+		t.Errorf("logged in %d times after reuse, want 2", n)
+	}
+}
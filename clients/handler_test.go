@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"learn/apperrors"
+	"learn/endpoints"
+	"learn/services"
+	"learn/transports"
+	"math"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// newArithmeticServer 使用transports.MakeHttpHandler启动服务，运算需要用户name/pwd登录
+func newArithmeticServer(t *testing.T) *httptest.Server {
+	users := services.NewMemoryUserStore()
+	if err := users.AddUser("1", "name", "pwd"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := services.NewTokenManager(services.DefaultSigningConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := services.NewMemoryRevocationList()
+	svc := services.NewArithmeticService(users, tokens, revoked)
+	registry := endpoints.DefaultRegistry()
+
+	eps := endpoints.ArithmeticEndpoints{
+		ArithmeticEndpoint: services.NewJWTAuthMiddleware(tokens, revoked)(endpoints.MakeArithmeticEndpoint(registry)),
+		OperationsEndpoint: endpoints.MakeOperationsEndpoint(registry),
+		AuthEndpoint:       endpoints.MakeAuthEndpoint(svc),
+	}
+	return httptest.NewServer(transports.MakeHttpHandler(context.Background(), eps, log.NewNopLogger()))
+}
+
+func TestClientAgainstHTTPHandler(t *testing.T) {
+	srv := newArithmeticServer(t)
+	defer srv.Close()
+	c := NewHTTPClient(srv.URL, WithCredentials("name", "pwd"), WithTimeout(time.Second))
+	defer c.Close()
+
+	if res := c.Add(1, 2); res != 3 {
+		t.Errorf("Add = %d, want 3", res)
+	}
+	if res, err := c.Factorial(5); err != nil || res != 120 {
+		t.Errorf("Factorial = %d, %v, want 120", res, err)
+	}
+	if res, err := c.Sqrt(2); err != nil || res != math.Sqrt2 {
+		t.Errorf("Sqrt = %v, %v, want %v", res, err, math.Sqrt2)
+	}
+	if _, err := c.CalculateInt64(services.OpAdd, math.MaxInt64, 1); apperrors.CodeOf(err) != apperrors.OutOfRange {
+		t.Errorf("int64 overflow: error = %v, want %s", err, apperrors.OutOfRange)
+	}
+	res, err := c.CalculateRat(services.OpDivide, big.NewRat(1, 3), big.NewRat(2, 1))
+	if err != nil || res.Cmp(big.NewRat(1, 6)) != 0 {
+		t.Errorf("CalculateRat = %v, %v, want 1/6", res, err)
+	}
+	if _, err := c.Divide(1, 0); apperrors.CodeOf(err) != apperrors.DivideByZero {
+		t.Errorf("Divide by zero: error = %v, want %s", err, apperrors.DivideByZero)
+	}
+	ops, err := c.Operations(context.Background())
+	if err != nil || len(ops) != len(endpoints.DefaultRegistry().Operations()) {
+		t.Errorf("Operations = %d, %v, want the default registry", len(ops), err)
+	}
+}
+
+func TestCalculateRatNilOperand(t *testing.T) {
+	srv := newArithmeticServer(t)
+	defer srv.Close()
+	c := NewHTTPClient(srv.URL, WithCredentials("name", "pwd"), WithTimeout(time.Second))
+	defer c.Close()
+
+	for _, operands := range [][2]*big.Rat{{big.NewRat(1, 2), nil}, {nil, big.NewRat(1, 2)}} {
+		if _, err := c.CalculateRat(services.OpAdd, operands[0], operands[1]); !errors.Is(err, services.ErrNilOperand) {
+			t.Errorf("CalculateRat(%v, %v): error = %v, want ErrNilOperand", operands[0], operands[1], err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"learn/apperrors"
+	"learn/endpoints"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+// httpCodec 单个接口的请求编码和响应解码
+type httpCodec struct {
+	encode kithttp.EncodeRequestFunc
+	decode kithttp.DecodeResponseFunc
+}
+
+var (
+	calculateCodec  = httpCodec{encodeCalculateRequest, decodeCalculateResponse}
+	operationsCodec = httpCodec{encodeEmptyRequest, decodeOperationsResponse}
+	evaluateCodec   = httpCodec{encodeJSONRequest, decodeEvaluateResponse}
+	batchCodec      = httpCodec{encodeBatchRequest, decodeBatchResponse}
+	healthCodec     = httpCodec{encodeEmptyRequest, decodeHealthResponse}
+	authCodec       = httpCodec{encodeJSONRequest, decodeAuthResponse}
+	refreshCodec    = httpCodec{encodeJSONRequest, decodeAuthResponse}
+	logoutCodec     = httpCodec{encodeJSONRequest, decodeLogoutResponse}
+)
+
+// breakerSettings 客户端所有熔断器共用的配置，修改后超时、错误率阈值等立即生效，
+// 但hystrix只在创建熔断器时按MaxConcurrentRequests分配并发名额，已创建的熔断器保持原来的并发上限，
+// 新的值只对之后新建的熔断器（如新发现的服务实例）生效
+type breakerSettings struct {
+	mu       sync.Mutex
+	config   hystrix.CommandConfig
+	commands []string
+}
+
+func (b *breakerSettings) register(command string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.commands = append(b.commands, command)
+	hystrix.ConfigureCommand(command, b.config)
+}
+
+func (b *breakerSettings) update(config hystrix.CommandConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.config = config
+	for _, command := range b.commands {
+		hystrix.ConfigureCommand(command, config)
+	}
+}
+
+// makeHTTPFactory 为服务实例创建Endpoint，每个实例和接口使用独立的熔断器
+func makeHTTPFactory(method, path string, codec httpCodec, o options, breakers *breakerSettings) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		if !strings.HasPrefix(instance, "http") {
+			instance = "http://" + instance
+		}
+		tgt, err := url.Parse(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+		tgt.Path = path
+
+		e := kithttp.NewClient(method, tgt, codec.encode, codec.decode,
+			kithttp.SetClient(o.httpClient),
+			kithttp.ClientBefore(tokenToHTTP),
+		).Endpoint()
+
+		command := method + " " + tgt.String()
+		breakers.register(command)
+		return breaker(command)(e), nil, nil
+	}
+}
+
+// breaker 熔断中间件，与circuitbreaker.Hystrix不同，客户端错误不计入失败次数
+func breaker(command string) endpoint.Middleware {
+	type result struct {
+		response interface{}
+		err      error
+	}
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			out := make(chan result, 1)
+			errs := hystrix.Go(command, func() error {
+				response, err := next(ctx, request)
+				if err != nil && !isClientError(err) {
+					return err
+				}
+				out <- result{response, err}
+				return nil
+			}, nil)
+			select {
+			case r := <-out:
+				return r.response, r.err
+			case err := <-errs:
+				return nil, err
+			}
+		}
+	}
+}
+
+func encodeJSONRequest(_ context.Context, r *http.Request, request interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(request); err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json;charset=utf-8")
+	r.Body = ioutil.NopCloser(&buf)
+	return nil
+}
+
+// calculateBody 运算请求体，操作数以JSON字符串发送，rat模式的分数如"1/3"不是合法的JSON数字
+type calculateBody struct {
+	RequestType string `json:"request_type"`
+	Mode        string `json:"mode,omitempty"`
+	A           string `json:"a"`
+	B           string `json:"b,omitempty"`
+}
+
+func encodeCalculateRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoints.ArithmeticRequest)
+	return encodeJSONRequest(ctx, r, calculateBody{
+		RequestType: req.RequestType,
+		Mode:        req.Mode,
+		A:           req.A.String(),
+		B:           req.B.String(),
+	})
+}
+
+func encodeEmptyRequest(_ context.Context, r *http.Request, _ interface{}) error {
+	r.Header.Set("Accept", "application/json")
+	return nil
+}
+
+// encodeBatchRequest 请求体为运算请求数组，并发数放在查询参数中
+func encodeBatchRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoints.BatchRequest)
+	if req.Concurrency > 0 {
+		r.URL.RawQuery = url.Values{"concurrency": []string{strconv.Itoa(req.Concurrency)}}.Encode()
+	}
+	return encodeJSONRequest(ctx, r, req.Items)
+}
+
+// decodeResponse 状态码为4xx、5xx时返回服务端的错误，否则将响应体解码到v
+func decodeResponse(resp *http.Response, v interface{}) error {
+	if resp.StatusCode >= http.StatusBadRequest {
+		// 错误响应格式为{"error":{"code":..,"message":..,"details":..}}
+		var s struct {
+			Error *apperrors.Error `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil || s.Error == nil {
+			return errors.New(resp.Status)
+		}
+		return s.Error
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeCalculateResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	var response struct {
+		Result   json.Number `json:"result"`
+		Mode     string      `json:"mode"`
+		Rational string      `json:"rational"`
+	}
+	if err := decodeResponse(resp, &response); err != nil {
+		return nil, err
+	}
+	return endpoints.ArithmeticResponse{Result: response.Result, Mode: response.Mode, Rational: response.Rational}, nil
+}
+
+func decodeOperationsResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	var response endpoints.OperationsResponse
+	err := decodeResponse(resp, &response)
+	return response, err
+}
+
+func decodeEvaluateResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	var response endpoints.EvaluateResponse
+	err := decodeResponse(resp, &response)
+	return response, err
+}
+
+func decodeBatchResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	var response endpoints.BatchResponse
+	err := decodeResponse(resp, &response)
+	return response, err
+}
+
+func decodeHealthResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	var response endpoints.HealthResponse
+	err := decodeResponse(resp, &response)
+	return response, err
+}
+
+func decodeAuthResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	var response endpoints.AuthResponse
+	err := decodeResponse(resp, &response)
+	return response, err
+}
+
+func decodeLogoutResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	var response endpoints.LogoutResponse
+	err := decodeResponse(resp, &response)
+	return response, err
+}
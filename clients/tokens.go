@@ -0,0 +1,129 @@
+package clients
+
+import (
+	"context"
+	"learn/endpoints"
+	"learn/services"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// expiryMargin 访问token在过期前提前刷新的时间
+const expiryMargin = 10 * time.Second
+
+type tokenContextKey struct{}
+
+// withToken 将访问token放入context，由tokenToHTTP设置到请求头
+func withToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tokenContextKey{}, token)
+}
+
+func tokenToHTTP(ctx context.Context, r *http.Request) context.Context {
+	if token, ok := ctx.Value(tokenContextKey{}).(string); ok {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return ctx
+}
+
+// tokenStore 保存当前的token，过期前使用刷新token续期，刷新失败且配置了登录凭证时重新登录
+type tokenStore struct {
+	mu        sync.Mutex
+	token     services.Token
+	expiresAt time.Time
+
+	name, pwd string
+	login     endpoint.Endpoint
+	refresh   endpoint.Endpoint
+}
+
+func (s *tokenStore) current() services.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+func (s *tokenStore) set(token services.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setLocked(token)
+}
+
+func (s *tokenStore) setLocked(token services.Token) {
+	s.token = token
+	s.expiresAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		s.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - expiryMargin)
+	}
+}
+
+// accessToken 返回可用的访问token，没有token且未配置登录凭证时返回空字符串，由服务端决定是否需要认证
+func (s *tokenStore) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.AccessToken != "" && (s.expiresAt.IsZero() || time.Now().Before(s.expiresAt)) {
+		return s.token.AccessToken, nil
+	}
+	if s.token.RefreshToken != "" {
+		token, err := callAuth(ctx, s.refresh, endpoints.RefreshRequest{RefreshToken: s.token.RefreshToken})
+		if err == nil {
+			s.setLocked(token)
+			return token.AccessToken, nil
+		}
+		if s.name == "" {
+			s.setLocked(services.Token{})
+			return "", err
+		}
+	}
+	if s.name == "" {
+		return s.token.AccessToken, nil
+	}
+	token, err := callAuth(ctx, s.login, endpoints.AuthRequest{Name: s.name, Pwd: s.pwd})
+	if err != nil {
+		return "", err
+	}
+	s.setLocked(token)
+	return token.AccessToken, nil
+}
+
+// invalidate 服务端拒绝token后清除访问token，返回是否可以重新获取
+func (s *tokenStore) invalidate(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token == "" || s.token.AccessToken != token {
+		return false
+	}
+	s.token.AccessToken = ""
+	return s.token.RefreshToken != "" || s.name != ""
+}
+
+func (s *tokenStore) doLogin(ctx context.Context, name, pwd string) (services.Token, error) {
+	token, err := callAuth(ctx, s.login, endpoints.AuthRequest{Name: name, Pwd: pwd})
+	if err == nil {
+		s.set(token)
+	}
+	return token, err
+}
+
+func (s *tokenStore) doRefresh(ctx context.Context, refreshToken string) (services.Token, error) {
+	token, err := callAuth(ctx, s.refresh, endpoints.RefreshRequest{RefreshToken: refreshToken})
+	if err == nil {
+		s.set(token)
+	}
+	return token, err
+}
+
+func callAuth(ctx context.Context, e endpoint.Endpoint, request interface{}) (services.Token, error) {
+	response, err := e(ctx, request)
+	if err != nil {
+		return services.Token{}, err
+	}
+	resp := response.(endpoints.AuthResponse)
+	return services.Token{AccessToken: resp.Token, RefreshToken: resp.RefreshToken, ExpiresIn: resp.ExpiresIn}, nil
+}
@@ -0,0 +1,203 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 算术服务、网关和服务发现三个程序共用的配置，各程序只读取自己需要的部分
+// 每个字段对应一个配置项，键为yaml标签按层级以点连接，如consul.host，
+// 同时也是命令行参数名，环境变量名为ARITHMETIC_加大写的键，点替换为下划线，如ARITHMETIC_CONSUL_HOST
+type Config struct {
+	Consul   ConsulConfig   `yaml:"consul"`
+	Zipkin   ZipkinConfig   `yaml:"zipkin"`
+	Service  ServiceConfig  `yaml:"service"`
+	Gateway  GatewayConfig  `yaml:"gateway"`
+	Discover DiscoverConfig `yaml:"discover"`
+
+	printOnly bool
+}
+
+// ConsulConfig 注册中心地址，KVKey为动态配置在KV中的键，为空时不监听，
+// RevokedPrefix为吊销token列表在KV中的前缀，为空时各实例在内存中分别保存
+type ConsulConfig struct {
+	Host          string `yaml:"host" usage:"consul ip address"`
+	Port          int    `yaml:"port" usage:"consul port"`
+	KVKey         string `yaml:"kv_key" usage:"consul KV key holding the dynamic configuration, empty disables reloading"`
+	RevokedPrefix string `yaml:"revoked_prefix" usage:"consul KV prefix for revoked token ids shared by all instances, empty keeps them in memory per instance"`
+}
+
+// Address consul地址，如localhost:8500
+func (c ConsulConfig) Address() string {
+	return c.Host + ":" + strconv.Itoa(c.Port)
+}
+
+// ZipkinConfig 链路追踪配置，URL为空时不上报
+type ZipkinConfig struct {
+	URL string `yaml:"url" usage:"Zipkin server url, empty disables tracing"`
+}
+
+// ServiceConfig 算术服务配置
+type ServiceConfig struct {
+	Host                string        `yaml:"host" usage:"service ip address registered in consul"`
+	Port                int           `yaml:"port" usage:"HTTP port"`
+	GRPCPort            int           `yaml:"grpc_port" usage:"gRPC port"`
+	UsersFile           string        `yaml:"users_file" usage:"JSON file with users and bcrypt password hashes"`
+	UsersReload         time.Duration `yaml:"users_reload" usage:"how often to check the users file for changes"`
+	JWTConfig           string        `yaml:"jwt_config" usage:"JSON file with token signing keys, lifetime and issuer"`
+	PolicyFile          string        `yaml:"policy_file" usage:"JSON file mapping operations to permitted roles and scopes"`
+	LimitsFile          string        `yaml:"limits_file" usage:"JSON file with per-client rate limit tiers and route quotas"`
+	RateLimitKeys       string        `yaml:"rate_limit_keys" usage:"comma separated client identities for rate limiting: subject, api_key, ip"`
+	RateLimitClients    int           `yaml:"rate_limit_clients" usage:"maximum number of per-client rate limit buckets kept in memory"`
+	RateLimitRedis      string        `yaml:"rate_limit_redis" usage:"redis://[:password@]host:port[/db] shared by all instances for rate limiting, empty limits per instance"`
+	RateLimitWait       time.Duration `yaml:"rate_limit_wait" usage:"wait up to this long for a token instead of rejecting with 429, 0 rejects immediately"`
+	ConcurrencyLimit    int           `yaml:"concurrency_limit" usage:"maximum concurrent requests per endpoint, the initial limit in adaptive mode, 0 disables"`
+	ConcurrencyAdaptive bool          `yaml:"concurrency_adaptive" usage:"adjust the concurrency limit from observed latency (AIMD)"`
+	ConcurrencyMax      int           `yaml:"concurrency_max" usage:"upper bound of the adaptive concurrency limit"`
+	ConcurrencyLatency  time.Duration `yaml:"concurrency_latency" usage:"latency above which the adaptive concurrency limit backs off"`
+	TrustForwardedFor   bool          `yaml:"trust_forwarded_for" usage:"take the client ip from the last X-Forwarded-For hop, enable behind the gateway"`
+	DrainPeriod         time.Duration `yaml:"drain_period" usage:"time to keep serving after being marked unready on shutdown"`
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout" usage:"time to wait for in-flight requests on shutdown"`
+	ReadHeaderTimeout   time.Duration `yaml:"read_header_timeout" usage:"time allowed to read HTTP request headers"`
+	ReadTimeout         time.Duration `yaml:"read_timeout" usage:"time allowed to read an entire HTTP request, including the body"`
+	WriteTimeout        time.Duration `yaml:"write_timeout" usage:"time allowed from the end of the request headers to the end of the response"`
+	IdleTimeout         time.Duration `yaml:"idle_timeout" usage:"time to keep an idle keep-alive HTTP connection open"`
+}
+
+// GatewayConfig 网关配置
+type GatewayConfig struct {
+	Port int `yaml:"port" usage:"gateway HTTP port"`
+}
+
+// DiscoverConfig 服务发现示例程序配置，Name和Pwd为调用算术服务使用的账号
+type DiscoverConfig struct {
+	Port int    `yaml:"port" usage:"discover HTTP port"`
+	Name string `yaml:"name" usage:"arithmetic service user name"`
+	Pwd  string `yaml:"pwd" usage:"arithmetic service user password"`
+}
+
+// Default 默认配置
+func Default() Config {
+	return Config{
+		Consul: ConsulConfig{Host: "localhost", Port: 8500, KVKey: "arithmetic/config"},
+		Service: ServiceConfig{
+			Host:               "localhost",
+			Port:               9000,
+			GRPCPort:           9002,
+			UsersReload:        10 * time.Second,
+			RateLimitKeys:      "subject,ip",
+			RateLimitClients:   10000,
+			ConcurrencyLimit:   100,
+			ConcurrencyMax:     1000,
+			ConcurrencyLatency: 500 * time.Millisecond,
+			DrainPeriod:        5 * time.Second,
+			ShutdownTimeout:    15 * time.Second,
+			ReadHeaderTimeout:  5 * time.Second,
+			ReadTimeout:        30 * time.Second,
+			WriteTimeout:       30 * time.Second,
+			IdleTimeout:        120 * time.Second,
+		},
+		Gateway:  GatewayConfig{Port: 9090},
+		Discover: DiscoverConfig{Port: 9001},
+	}
+}
+
+// Validate 检查配置项的取值，返回所有不合法的配置项
+func (c Config) Validate() error {
+	var errs []string
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, key+": "+fmt.Sprintf(format, args...))
+	}
+	checkPort := func(key string, port int) {
+		if port < 1 || port > 65535 {
+			invalid(key, "%d is not a valid port", port)
+		}
+	}
+	checkPositive := func(key string, d time.Duration) {
+		if d <= 0 {
+			invalid(key, "must be positive")
+		}
+	}
+
+	if c.Consul.Host == "" {
+		invalid("consul.host", "is required")
+	}
+	checkPort("consul.port", c.Consul.Port)
+	if c.Consul.RevokedPrefix != "" && !strings.HasSuffix(c.Consul.RevokedPrefix, "/") {
+		invalid("consul.revoked_prefix", "must end with /")
+	}
+	if c.Zipkin.URL != "" {
+		if u, err := url.Parse(c.Zipkin.URL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			invalid("zipkin.url", "%q is not an http(s) url", c.Zipkin.URL)
+		}
+	}
+	if c.Service.Host == "" {
+		invalid("service.host", "is required")
+	}
+	checkPort("service.port", c.Service.Port)
+	checkPort("service.grpc_port", c.Service.GRPCPort)
+	if c.Service.GRPCPort == c.Service.Port {
+		invalid("service.grpc_port", "must differ from service.port")
+	}
+	if strings.TrimSpace(c.Service.RateLimitKeys) == "" {
+		invalid("service.rate_limit_keys", "is required")
+	}
+	if c.Service.RateLimitClients < 1 {
+		invalid("service.rate_limit_clients", "must be at least 1")
+	}
+	if c.Service.RateLimitWait < 0 {
+		invalid("service.rate_limit_wait", "must not be negative")
+	}
+	if c.Service.ConcurrencyLimit < 0 {
+		invalid("service.concurrency_limit", "must not be negative")
+	}
+	if c.Service.ConcurrencyAdaptive {
+		if c.Service.ConcurrencyLimit == 0 {
+			invalid("service.concurrency_adaptive", "requires service.concurrency_limit")
+		}
+		if c.Service.ConcurrencyMax < c.Service.ConcurrencyLimit {
+			invalid("service.concurrency_max", "must not be less than service.concurrency_limit")
+		}
+		if c.Service.ConcurrencyLatency <= 0 {
+			invalid("service.concurrency_latency", "must be positive")
+		}
+	}
+	if c.Service.UsersFile != "" && c.Service.UsersReload <= 0 {
+		invalid("service.users_reload", "must be positive")
+	}
+	if c.Service.RateLimitRedis != "" {
+		if u, err := url.Parse(c.Service.RateLimitRedis); err != nil || u.Scheme != "redis" || u.Host == "" {
+			invalid("service.rate_limit_redis", "%q is not a redis://host:port url", c.Service.RateLimitRedis)
+		}
+	}
+	if c.Service.DrainPeriod < 0 {
+		invalid("service.drain_period", "must not be negative")
+	}
+	if c.Service.ShutdownTimeout <= 0 {
+		invalid("service.shutdown_timeout", "must be positive")
+	}
+	checkPositive("service.read_header_timeout", c.Service.ReadHeaderTimeout)
+	checkPositive("service.read_timeout", c.Service.ReadTimeout)
+	checkPositive("service.write_timeout", c.Service.WriteTimeout)
+	checkPositive("service.idle_timeout", c.Service.IdleTimeout)
+	if c.Service.ReadHeaderTimeout > c.Service.ReadTimeout {
+		invalid("service.read_header_timeout", "must not exceed service.read_timeout")
+	}
+	// 等待令牌的请求需要在写超时之前完成
+	if c.Service.WriteTimeout > 0 && c.Service.WriteTimeout <= c.Service.RateLimitWait {
+		invalid("service.write_timeout", "must be longer than service.rate_limit_wait")
+	}
+	checkPort("gateway.port", c.Gateway.Port)
+	checkPort("discover.port", c.Discover.Port)
+	if (c.Discover.Name == "") != (c.Discover.Pwd == "") {
+		invalid("discover.name", "discover.name and discover.pwd must be given together")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateDefault(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default configuration is invalid: %v", err)
+	}
+}
+
+func TestValidateServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*ServiceConfig)
+		key    string
+	}{
+		{"zero read header timeout", func(s *ServiceConfig) { s.ReadHeaderTimeout = 0 }, "service.read_header_timeout: must be positive"},
+		{"negative read timeout", func(s *ServiceConfig) { s.ReadTimeout = -time.Second }, "service.read_timeout: must be positive"},
+		{"zero write timeout", func(s *ServiceConfig) { s.WriteTimeout = 0 }, "service.write_timeout: must be positive"},
+		{"zero idle timeout", func(s *ServiceConfig) { s.IdleTimeout = 0 }, "service.idle_timeout: must be positive"},
+		{"header timeout above read timeout", func(s *ServiceConfig) { s.ReadHeaderTimeout = time.Minute }, "service.read_header_timeout: must not exceed"},
+		{"write timeout within rate limit wait", func(s *ServiceConfig) { s.RateLimitWait = s.WriteTimeout }, "service.write_timeout: must be longer than"},
+	}
+	for _, tt := range tests {
+		c := Default()
+		tt.modify(&c.Service)
+		err := c.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.key) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.key)
+		}
+	}
+}
+
+func TestValidateRevokedPrefix(t *testing.T) {
+	c := Default()
+	c.Consul.RevokedPrefix = "arithmetic/revoked"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "consul.revoked_prefix: must end with /") {
+		t.Errorf("error = %v, want the revoked prefix rejected", err)
+	}
+	c.Consul.RevokedPrefix = "arithmetic/revoked/"
+	if err := c.Validate(); err != nil {
+		t.Errorf("prefix with a trailing slash: %v", err)
+	}
+}
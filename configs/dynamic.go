@@ -0,0 +1,244 @@
+package configs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/hashicorp/consul/api"
+	"gopkg.in/yaml.v3"
+)
+
+// Dynamic 运行时可以修改的配置，保存在Consul KV中，值为YAML
+// KV中未给出的配置项使用启动时的值，删除KV后恢复启动时的配置
+type Dynamic struct {
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Hystrix   HystrixConfig   `yaml:"hystrix"`
+	Token     TokenConfig     `yaml:"token"`
+}
+
+// RateLimitConfig 限流配置，每Interval补充一个令牌，最多积累Burst个
+type RateLimitConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	Burst    int           `yaml:"burst"`
+}
+
+// HystrixConfig 客户端熔断配置，MaxConcurrentRequests只对修改后新建的熔断器生效
+type HystrixConfig struct {
+	Timeout               time.Duration `yaml:"timeout"`
+	MaxConcurrentRequests int           `yaml:"max_concurrent_requests"`
+	ErrorPercentThreshold int           `yaml:"error_percent_threshold"`
+}
+
+// TokenConfig 新签发token的有效期
+type TokenConfig struct {
+	AccessLifetime  time.Duration `yaml:"access_lifetime"`
+	RefreshLifetime time.Duration `yaml:"refresh_lifetime"`
+}
+
+// DefaultDynamic 动态配置的默认值
+func DefaultDynamic() Dynamic {
+	return Dynamic{
+		RateLimit: RateLimitConfig{Interval: 4 * time.Second, Burst: 3},
+		Hystrix:   HystrixConfig{Timeout: time.Second, MaxConcurrentRequests: 10, ErrorPercentThreshold: 50},
+		Token:     TokenConfig{AccessLifetime: 2 * time.Minute, RefreshLifetime: 7 * 24 * time.Hour},
+	}
+}
+
+// Validate 检查动态配置的取值，返回所有不合法的配置项
+func (d Dynamic) Validate() error {
+	var errs []string
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if d.RateLimit.Interval <= 0 {
+		invalid("rate_limit.interval", "must be positive")
+	}
+	if d.RateLimit.Burst < 1 {
+		invalid("rate_limit.burst", "must be at least 1")
+	}
+	if d.Hystrix.Timeout < time.Millisecond || d.Hystrix.Timeout > time.Minute {
+		invalid("hystrix.timeout", "must be between 1ms and 1m")
+	}
+	if d.Hystrix.MaxConcurrentRequests < 1 {
+		invalid("hystrix.max_concurrent_requests", "must be at least 1")
+	}
+	if d.Hystrix.ErrorPercentThreshold < 1 || d.Hystrix.ErrorPercentThreshold > 100 {
+		invalid("hystrix.error_percent_threshold", "must be between 1 and 100")
+	}
+	if d.Token.AccessLifetime <= 0 {
+		invalid("token.access_lifetime", "must be positive")
+	}
+	if d.Token.RefreshLifetime <= d.Token.AccessLifetime {
+		invalid("token.refresh_lifetime", "must be longer than token.access_lifetime")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid dynamic configuration: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// parseDynamic 在base的基础上解析YAML，未知的配置项视为错误
+func parseDynamic(base Dynamic, data []byte) (Dynamic, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&base); err != nil && err != io.EOF {
+		return base, err
+	}
+	return base, base.Validate()
+}
+
+// change 单个配置项的变化
+type change struct {
+	key      string
+	old, new string
+}
+
+// diff 比较两份动态配置，按配置项返回变化
+func diff(old, new Dynamic) []change {
+	oldFields := fields(reflect.ValueOf(&old).Elem(), "")
+	newFields := fields(reflect.ValueOf(&new).Elem(), "")
+	var changes []change
+	for i, f := range newFields {
+		o, n := fmt.Sprint(oldFields[i].value.Interface()), fmt.Sprint(f.value.Interface())
+		if o != n {
+			changes = append(changes, change{key: f.key, old: o, new: n})
+		}
+	}
+	return changes
+}
+
+// deferredNotes 修改后不会对已有对象生效的配置项，说明记录在审计日志的note中
+var deferredNotes = map[string]string{
+	"hystrix.max_concurrent_requests": "applies only to circuits created after the change, existing circuits keep their concurrency pool",
+}
+
+type applier struct {
+	name  string
+	apply func(Dynamic) error
+}
+
+// Watcher 监听Consul KV中的动态配置并推送给各组件
+// 不合法的配置不会生效，组件应用失败时所有组件回滚到上一份生效的配置，每项变化都记录审计日志
+type Watcher struct {
+	kv     *api.KV
+	key    string
+	logger log.Logger
+
+	mu       sync.Mutex
+	baseline Dynamic
+	current  Dynamic
+	appliers []applier
+}
+
+// NewWatcher 创建监听key的Watcher，baseline为启动时的配置，KV不存在时使用
+func NewWatcher(client *api.Client, key string, baseline Dynamic, logger log.Logger) *Watcher {
+	return &Watcher{
+		kv:       client.KV(),
+		key:      key,
+		logger:   log.With(logger, "component", "dynamic-config", "key", key),
+		baseline: baseline,
+		current:  baseline,
+	}
+}
+
+// OnChange 注册配置变化时调用的函数，apply返回错误时触发回滚
+func (w *Watcher) OnChange(name string, apply func(Dynamic) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.appliers = append(w.appliers, applier{name, apply})
+}
+
+// Current 当前生效的配置
+func (w *Watcher) Current() Dynamic {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Run 使用阻塞查询监听KV变化，直到ctx结束
+func (w *Watcher) Run(ctx context.Context) error {
+	var index uint64
+	backoff := time.Second
+	for {
+		opts := (&api.QueryOptions{WaitIndex: index, WaitTime: 5 * time.Minute}).WithContext(ctx)
+		pair, meta, err := w.kv.Get(w.key, opts)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			w.logger.Log("err", err, "retry_in", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+
+		// 索引变小说明Consul状态被重置，需要从头开始
+		if meta.LastIndex < index {
+			index = 0
+			continue
+		}
+		if meta.LastIndex == index {
+			continue
+		}
+		index = meta.LastIndex
+
+		var data []byte
+		if pair != nil {
+			data = pair.Value
+		}
+		w.update(data, index)
+	}
+}
+
+// update 解析、校验并应用新的配置，data为nil表示KV已删除
+func (w *Watcher) update(data []byte, index uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, err := parseDynamic(w.baseline, data)
+	if err != nil {
+		w.logger.Log("event", "rejected", "index", index, "err", err, "keep", "last-known-good")
+		return
+	}
+	changes := diff(w.current, next)
+	if len(changes) == 0 {
+		return
+	}
+
+	for i, a := range w.appliers {
+		if err := a.apply(next); err != nil {
+			w.logger.Log("event", "rollback", "index", index, "applier", a.name, "err", err)
+			for _, applied := range w.appliers[:i+1] {
+				if err := applied.apply(w.current); err != nil {
+					w.logger.Log("event", "rollback-failed", "applier", applied.name, "err", err)
+				}
+			}
+			return
+		}
+	}
+
+	w.current = next
+	for _, c := range changes {
+		keyvals := []interface{}{"event", "applied", "index", index, "setting", c.key, "old", c.old, "new", c.new}
+		if note, ok := deferredNotes[c.key]; ok {
+			keyvals = append(keyvals, "note", note)
+		}
+		w.logger.Log(keyvals...)
+	}
+}
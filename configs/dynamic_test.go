@@ -0,0 +1,71 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/hashicorp/consul/api"
+)
+
+func TestWatcherUpdate(t *testing.T) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	w := NewWatcher(client, "arithmetic/config", DefaultDynamic(), log.NewLogfmtLogger(&logs))
+
+	var bursts []int
+	fail := false
+	w.OnChange("rate_limiter", func(d Dynamic) error {
+		bursts = append(bursts, d.RateLimit.Burst)
+		return nil
+	})
+	w.OnChange("breaker", func(d Dynamic) error {
+		if fail {
+			return errors.New("breaker unavailable")
+		}
+		return nil
+	})
+
+	w.update([]byte("rate_limit: {burst: 7}\nhystrix: {max_concurrent_requests: 20}\n"), 1)
+	if got := w.Current().RateLimit.Burst; got != 7 {
+		t.Fatalf("burst = %d, want 7", got)
+	}
+	if !strings.Contains(logs.String(), `setting=hystrix.max_concurrent_requests old=10 new=20 note="applies only to circuits created after the change`) {
+		t.Errorf("applied log has no note for max_concurrent_requests:\n%s", logs.String())
+	}
+
+	logs.Reset()
+	w.update([]byte("rate_limit: {burst: 0}\n"), 2)
+	w.update([]byte("unknown: 1\n"), 3)
+	if got := w.Current().RateLimit.Burst; got != 7 || strings.Count(logs.String(), "event=rejected") != 2 {
+		t.Errorf("invalid updates: burst = %d, logs:\n%s", got, logs.String())
+	}
+
+	logs.Reset()
+	fail = true
+	w.update([]byte("rate_limit: {burst: 9}\n"), 4)
+	if got := w.Current().RateLimit.Burst; got != 7 || !strings.Contains(logs.String(), "event=rollback index=4 applier=breaker") {
+		t.Errorf("failed apply: burst = %d, logs:\n%s", got, logs.String())
+	}
+
+	fail = false
+	w.update(nil, 5)
+	if got := w.Current(); got != DefaultDynamic() {
+		t.Errorf("after delete = %+v, want the startup values", got)
+	}
+	want := []int{7, 9, 7, 3}
+	if len(bursts) != len(want) {
+		t.Fatalf("applied bursts = %v, want %v", bursts, want)
+	}
+	for i := range want {
+		if bursts[i] != want[i] {
+			t.Errorf("applied bursts = %v, want %v", bursts, want)
+			break
+		}
+	}
+}
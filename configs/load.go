@@ -0,0 +1,224 @@
+package configs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量前缀，ARITHMETIC_CONFIG指定配置文件
+const EnvPrefix = "ARITHMETIC_"
+
+// aliases 旧的命令行参数名，仍可使用
+var aliases = map[string]string{
+	"consul_host":         "consul.host",
+	"consul_port":         "consul.port",
+	"service_host":        "service.host",
+	"service_port":        "service.port",
+	"grpc_port":           "service.grpc_port",
+	"users_file":          "service.users_file",
+	"jwt_config":          "service.jwt_config",
+	"policy_file":         "service.policy_file",
+	"drain_period":        "service.drain_period",
+	"shutdown_timeout":    "service.shutdown_timeout",
+	"read_header_timeout": "service.read_header_timeout",
+	"read_timeout":        "service.read_timeout",
+	"write_timeout":       "service.write_timeout",
+	"idle_timeout":        "service.idle_timeout",
+	"arithmetic.name":     "discover.name",
+	"arithmetic.pwd":      "discover.pwd",
+}
+
+// secretKeys 输出配置时隐藏的配置项
+var secretKeys = map[string]bool{"discover.pwd": true, "service.rate_limit_redis": true}
+
+// field 单个配置项
+type field struct {
+	key   string
+	usage string
+	value reflect.Value
+}
+
+// fields 按yaml标签展开结构体的配置项
+func fields(v reflect.Value, prefix string) []field {
+	var res []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		key := prefix + strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if sf.Type.Kind() == reflect.Struct {
+			res = append(res, fields(v.Field(i), key+".")...)
+			continue
+		}
+		res = append(res, field{key: key, usage: sf.Tag.Get("usage"), value: v.Field(i)})
+	}
+	return res
+}
+
+// envName 配置项对应的环境变量名
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// set 将字符串解析为配置项的类型
+func (f field) set(s string) error {
+	switch {
+	case f.value.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a valid duration", f.key, s)
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a valid integer", f.key, s)
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a valid boolean", f.key, s)
+		}
+		f.value.SetBool(b)
+	default:
+		f.value.SetString(s)
+	}
+	return nil
+}
+
+// flagValue 记录命令行中显式指定的参数，在配置文件和环境变量之后生效
+type flagValue struct {
+	key    string
+	def    string
+	isBool bool
+	values *[][2]string
+}
+
+func (v flagValue) String() string {
+	return v.def
+}
+
+// IsBoolFlag 布尔型配置项可以省略值，如-service.trust_forwarded_for
+func (v flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+func (v flagValue) Set(s string) error {
+	*v.values = append(*v.values, [2]string{v.key, s})
+	return nil
+}
+
+// usageError 命令行参数错误，FlagSet已输出错误信息和用法
+type usageError struct {
+	error
+}
+
+// Load 加载配置，优先级从低到高依次为默认值、配置文件、环境变量、命令行参数
+// 配置文件通过-config或ARITHMETIC_CONFIG指定，args不含程序名
+func Load(program string, args []string) (Config, error) {
+	cfg := Default()
+	all := fields(reflect.ValueOf(&cfg).Elem(), "")
+	byKey := make(map[string]field, len(all))
+
+	fs := flag.NewFlagSet(program, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "YAML configuration file")
+	printConfig := fs.Bool("print-config", false, "print the merged configuration as YAML and exit")
+	var flagValues [][2]string
+	for _, f := range all {
+		byKey[f.key] = f
+		fs.Var(flagValue{f.key, fmt.Sprint(f.value.Interface()), f.value.Kind() == reflect.Bool, &flagValues}, f.key, f.usage+" (env "+envName(f.key)+")")
+	}
+	for alias, key := range aliases {
+		fs.Var(flagValue{key, fmt.Sprint(byKey[key].value.Interface()), byKey[key].value.Kind() == reflect.Bool, &flagValues}, alias, "deprecated, use -"+key)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, usageError{err}
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	cfg.printOnly = *printConfig
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	for _, f := range all {
+		if s, ok := os.LookupEnv(envName(f.key)); ok {
+			if err := f.set(s); err != nil {
+				return cfg, fmt.Errorf("%s: %v", envName(f.key), err)
+			}
+		}
+	}
+	for _, kv := range flagValues {
+		if err := byKey[kv[0]].set(kv[1]); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// loadFile 读取YAML配置文件，未知的配置项视为错误
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// WriteYAML 以YAML格式输出配置，密码等敏感配置项被隐藏
+func (c Config) WriteYAML(w io.Writer) error {
+	for _, f := range fields(reflect.ValueOf(&c).Elem(), "") {
+		if secretKeys[f.key] && f.value.String() != "" {
+			f.value.SetString("******")
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// MustLoad 从os.Args加载配置，配置错误时输出错误并以2退出，
+// 指定-print-config时输出合并后的配置并退出
+func MustLoad(program string) Config {
+	cfg, err := Load(program, os.Args[1:])
+	var usageErr usageError
+	switch {
+	case errors.As(err, &usageErr):
+		if usageErr.error == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	case cfg.printOnly:
+		cfg.WriteYAML(os.Stdout)
+		os.Exit(0)
+	}
+	return cfg
+}
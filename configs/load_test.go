@@ -0,0 +1,96 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadAliases(t *testing.T) {
+	cfg, err := Load("test", []string{
+		"-consul_host=consul", "-service_port=9100", "-grpc_port=9102",
+		"-shutdown_timeout=20s", "-arithmetic.name=alice", "-arithmetic.pwd=secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Consul.Host != "consul" || cfg.Service.Port != 9100 || cfg.Service.GRPCPort != 9102 || cfg.Service.ShutdownTimeout != 20*time.Second {
+		t.Errorf("service aliases not applied: %+v %+v", cfg.Consul, cfg.Service)
+	}
+	if cfg.Discover.Name != "alice" || cfg.Discover.Pwd != "secret" {
+		t.Errorf("discover = %+v, want the -arithmetic.name and -arithmetic.pwd values", cfg.Discover)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	data := "service:\n  port: 9100\n  grpc_port: 9102\n  host: file\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("ARITHMETIC_SERVICE_GRPC_PORT", "9202")
+	os.Setenv("ARITHMETIC_SERVICE_HOST", "env")
+	defer os.Unsetenv("ARITHMETIC_SERVICE_GRPC_PORT")
+	defer os.Unsetenv("ARITHMETIC_SERVICE_HOST")
+
+	cfg, err := Load("test", []string{"-config", path, "-service.host=flag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Service.Port != 9100 || cfg.Service.GRPCPort != 9202 || cfg.Service.Host != "flag" {
+		t.Errorf("service = %d %d %s, want the file port, env gRPC port and flag host", cfg.Service.Port, cfg.Service.GRPCPort, cfg.Service.Host)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte("service:\n  prot: 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-config", path}, "field prot not found"},
+		{[]string{"-service.port=abc"}, `"abc" is not a valid integer`},
+		{[]string{"-gateway.port=70000"}, "gateway.port: 70000 is not a valid port"},
+		{[]string{"-arithmetic.name=alice"}, "discover.name and discover.pwd must be given together"},
+	}
+	for _, tt := range tests {
+		_, err := Load("test", tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load(%v) error = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestWriteYAMLHidesSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Discover.Pwd = "secret"
+	cfg.Service.RateLimitRedis = "redis://:secret@localhost:6379/0"
+	var b strings.Builder
+	if err := cfg.WriteYAML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "secret") {
+		t.Errorf("secrets in the printed config:\n%s", b.String())
+	}
+	if cfg.Discover.Pwd != "secret" {
+		t.Error("WriteYAML modified the config")
+	}
+}
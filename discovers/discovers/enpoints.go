@@ -2,19 +2,21 @@ package main
 
 import (
 	"context"
+	"learn/clients"
+	"learn/configs"
+	"learn/endpoints"
 	"time"
 
+	"github.com/afex/hystrix-go/hystrix"
 	"github.com/go-kit/kit/log"
 
 	"github.com/go-kit/kit/endpoint"
-	"github.com/go-kit/kit/sd"
 	"github.com/go-kit/kit/sd/consul"
-	"github.com/go-kit/kit/sd/lb"
 )
 
-// MakeDiscoverEndpoint 使用consul.Client创建服务发现Endpoint
-// 为了方便这里默认了一些参数
-func MakeDiscoverEndpoint(ctx context.Context, client consul.Client, logger log.Logger) endpoint.Endpoint {
+// NewArithmeticClient 使用consul.Client创建算术服务客户端
+// 为了方便这里默认了一些参数，name和pwd为调用算术服务使用的账号，breaker为熔断配置
+func NewArithmeticClient(client consul.Client, logger log.Logger, name, pwd string, breaker configs.HystrixConfig) *clients.Client {
 	serviceName := "arithmetic"
 	tags := []string{"arithmetic", "raysonxin"}
 	passingOnly := true
@@ -25,17 +27,27 @@ func MakeDiscoverEndpoint(ctx context.Context, client consul.Client, logger log.
 	// 可实时查询服务实例的状态信息
 	instancer := consul.NewInstancer(client, logger, serviceName, tags, passingOnly)
 
-	//针对calculate接口创建sd.Factory
-	factory := arithmeticFactory(ctx, "POST", "calculate")
-
-	//使用consul连接实例（发现服务系统）、factory创建sd.Factory
-	endpointer := sd.NewEndpointer(instancer, factory, logger)
-
-	//创建RoundRibbon负载均衡器
-	balancer := lb.NewRoundRobin(endpointer)
+	//基于服务发现创建客户端，客户端负责负载均衡、重试、熔断和登录
+	return clients.NewClient(instancer, logger,
+		clients.WithTimeout(duration),
+		clients.WithRetries(2),
+		clients.WithCredentials(name, pwd),
+		clients.WithBreaker(breakerConfig(breaker)),
+	)
+}
 
-	//为负载均衡器增加重试功能，同时该对象为endpoint.Endpoint
-	retry := lb.Retry(1, duration, balancer)
+// breakerConfig 转换为hystrix的熔断配置
+func breakerConfig(c configs.HystrixConfig) hystrix.CommandConfig {
+	return hystrix.CommandConfig{
+		Timeout:               int(c.Timeout / time.Millisecond),
+		MaxConcurrentRequests: c.MaxConcurrentRequests,
+		ErrorPercentThreshold: c.ErrorPercentThreshold,
+	}
+}
 
-	return retry
+// MakeDiscoverEndpoint 通过算术服务客户端执行运算请求
+func MakeDiscoverEndpoint(arithmeticClient *clients.Client) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		return arithmeticClient.Calculate(ctx, request.(endpoints.ArithmeticRequest))
+	}
 }
@@ -1,63 +0,0 @@
-package main
-
-import (
-	"context"
-	"encoding/json"
-	"errors"
-	"io"
-	"learn/endpoints"
-	"net/http"
-	"net/url"
-	"strconv"
-	"strings"
-
-	"github.com/go-kit/kit/endpoint"
-	"github.com/go-kit/kit/sd"
-	kithttp "github.com/go-kit/kit/transport/http"
-)
-
-func arithmeticFactory(_ context.Context, method, path string) sd.Factory {
-	return func(instance string) (endpoint endpoint.Endpoint, closer io.Closer, err error) {
-		if !strings.HasPrefix(instance, "http") {
-			instance = "http://" + instance
-		}
-
-		tgt, err := url.Parse(instance)
-		if err != nil {
-			return nil, nil, err
-		}
-		tgt.Path = path
-
-		var (
-			enc kithttp.EncodeRequestFunc
-			dec kithttp.DecodeResponseFunc
-		)
-		enc, dec = encodeArithmeticRequest, decodeArithmeticReponse
-
-		return kithttp.NewClient(method, tgt, enc, dec).Endpoint(), nil, nil
-	}
-}
-
-func encodeArithmeticRequest(_ context.Context, req *http.Request, request interface{}) error {
-	arithReq := request.(endpoints.ArithmeticRequest)
-	p := "/" + arithReq.RequestType + "/" + strconv.Itoa(arithReq.A) + "/" + strconv.Itoa(arithReq.B)
-	req.URL.Path += p
-	return nil
-}
-
-func decodeArithmeticReponse(_ context.Context, resp *http.Response) (interface{}, error) {
-	var response endpoints.ArithmeticResponse
-	var s map[string]interface{}
-
-	if respCode := resp.StatusCode; respCode >= 400 {
-		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
-			return nil, err
-		}
-		return nil, errors.New(s["error"].(string) + "\n")
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
-	}
-	return response, nil
-}
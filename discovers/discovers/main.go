@@ -2,25 +2,23 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"github.com/go-kit/kit/sd/consul"
 	"github.com/go-kit/log"
 	"github.com/hashicorp/consul/api"
+	"learn/configs"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 )
 
 func main() {
 
-	// 创建环境变量
-	var (
-		consulHost = flag.String("consul.host", "", "consul server ip address")
-		consulPort = flag.String("consul.port", "", "consul server port")
-	)
-	flag.Parse()
+	// 加载配置，与算术服务共用configs
+	conf := configs.MustLoad("discover")
+	port := strconv.Itoa(conf.Discover.Port)
 
 	//创建日志组件
 	var logger log.Logger
@@ -31,12 +29,16 @@ func main() {
 	}
 
 	//创建consul客户端对象
-	var client consul.Client
+	var (
+		client       consul.Client
+		consulClient *api.Client
+	)
 	{
 		consulConfig := api.DefaultConfig()
 
-		consulConfig.Address = "http://" + *consulHost + ":" + *consulPort
-		consulClient, err := api.NewClient(consulConfig)
+		consulConfig.Address = "http://" + conf.Consul.Address()
+		var err error
+		consulClient, err = api.NewClient(consulConfig)
 
 		if err != nil {
 			logger.Log("err", err)
@@ -47,23 +49,35 @@ func main() {
 
 	ctx := context.Background()
 
-	//创建Endpoint
-	discoverEndpoint := MakeDiscoverEndpoint(ctx, client, logger)
+	//创建算术服务客户端和Endpoint
+	dynamic := configs.DefaultDynamic()
+	arithmeticClient := NewArithmeticClient(client, logger, conf.Discover.Name, conf.Discover.Pwd, dynamic.Hystrix)
+	discoverEndpoint := MakeDiscoverEndpoint(arithmeticClient)
+
+	//熔断配置可以通过Consul KV修改，无需重启
+	if conf.Consul.KVKey != "" {
+		watcher := configs.NewWatcher(consulClient, conf.Consul.KVKey, dynamic, logger)
+		watcher.OnChange("hystrix", func(d configs.Dynamic) error {
+			arithmeticClient.SetBreaker(breakerConfig(d.Hystrix))
+			return nil
+		})
+		go watcher.Run(ctx)
+	}
 
 	//创建传输层
 	r := MakeHttpHandler(discoverEndpoint)
 
 	errc := make(chan error)
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		errc <- fmt.Errorf("%s", <-c)
 	}()
 
 	//开始监听
 	go func() {
-		logger.Log("transport", "HTTP", "addr", "9001")
-		errc <- http.ListenAndServe(":9001", r)
+		logger.Log("transport", "HTTP", "addr", port)
+		errc <- http.ListenAndServe(":"+port, r)
 	}()
 
 	// 开始运行，等待结束
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"learn/apperrors"
+	"learn/endpoints"
 	"net/http"
 
 	"github.com/go-kit/kit/endpoint"
@@ -17,28 +19,16 @@ func MakeHttpHandler(endpoint endpoint.Endpoint) http.Handler {
 		endpoint,
 		decodeDiscoverRequest,
 		encodeDiscoverResponse,
+		kithttp.ServerErrorEncoder(encodeDiscoverError),
 	))
 
 	return r
 }
 
-// ArithmeticRequest define request struct
-type ArithmeticRequest struct {
-	RequestType string `json:"request_type"`
-	A           int    `json:"a"`
-	B           int    `json:"b"`
-}
-
-// ArithmeticResponse define response struct
-type ArithmeticResponse struct {
-	Result int   `json:"result"`
-	Error  error `json:"error"`
-}
-
 func decodeDiscoverRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request ArithmeticRequest
+	var request endpoints.ArithmeticRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+		return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
 	}
 	return request, nil
 }
@@ -47,3 +37,13 @@ func encodeDiscoverResponse(_ context.Context, w http.ResponseWriter, response i
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
 	return json.NewEncoder(w).Encode(response)
 }
+
+// encodeDiscoverError 透传算术服务的错误，格式与算术服务一致
+func encodeDiscoverError(_ context.Context, err error, w http.ResponseWriter) {
+	appErr := apperrors.From(err)
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(apperrors.HTTPStatus(appErr.Code))
+	json.NewEncoder(w).Encode(struct {
+		Error *apperrors.Error `json:"error"`
+	}{appErr})
+}
@@ -0,0 +1,207 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"learn/apperrors"
+	"learn/services"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Permission 授权规则，拥有任一角色或权限范围即满足
+type Permission struct {
+	Roles  []string `json:"roles"`
+	Scopes []string `json:"scopes"`
+	// MaxOperand 操作数绝对值上限，0表示不限制
+	MaxOperand int `json:"max_operand"`
+}
+
+// Policy 运算类型到授权规则的映射，运算类型不区分大小写
+// 未配置的运算类型一律拒绝
+type Policy map[string][]Permission
+
+// DefaultPolicy 默认策略：reader可以做加减法、取余、公约数/公倍数、平方根及小数字乘法，
+// 除法、幂和阶乘仅限power，power不受限制
+func DefaultPolicy() Policy {
+	all := Permission{Roles: []string{"power"}, Scopes: []string{"arithmetic:all"}}
+	return Policy{
+		services.OpAdd:       {{Roles: []string{"reader"}}, all},
+		services.OpSubtract:  {{Roles: []string{"reader"}}, all},
+		services.OpMultiply:  {{Roles: []string{"reader"}, MaxOperand: 1000}, all},
+		services.OpDivide:    {all},
+		services.OpMod:       {{Roles: []string{"reader"}}, all},
+		services.OpGcd:       {{Roles: []string{"reader"}}, all},
+		services.OpLcm:       {{Roles: []string{"reader"}}, all},
+		services.OpSqrt:      {{Roles: []string{"reader"}}, all},
+		services.OpPow:       {all},
+		services.OpFactorial: {all},
+	}
+}
+
+// LoadPolicy 从JSON文件加载授权策略
+func LoadPolicy(path string) (Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// permissions 查询运算类型对应的规则，策略中的键可以是运算的别名
+func (p Policy) permissions(registry *Registry, requestType string) []Permission {
+	want := normalizeOperation(registry, requestType)
+	for op, perms := range p {
+		if normalizeOperation(registry, op) == want {
+			return perms
+		}
+	}
+	return nil
+}
+
+// normalizeOperation 已注册的运算使用标准名称，未知运算统一为小写
+func normalizeOperation(registry *Registry, requestType string) string {
+	if op, err := registry.Lookup(requestType); err == nil {
+		return op.Name
+	}
+	return strings.ToLower(requestType)
+}
+
+// matches 判断声明是否拥有规则中的角色或权限范围
+func (perm Permission) matches(claims *services.ArithmeticCustomClaims) bool {
+	for _, role := range perm.Roles {
+		if claims.HasRole(role) {
+			return true
+		}
+	}
+	for _, scope := range perm.Scopes {
+		if claims.HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange 判断操作数是否在规则允许的范围内
+func (perm Permission) inRange(req ArithmeticRequest) bool {
+	if perm.MaxOperand == 0 {
+		return true
+	}
+	return withinLimit(req.A, perm.MaxOperand) && withinLimit(req.B, perm.MaxOperand)
+}
+
+// withinLimit 判断操作数绝对值是否不超过limit，无法解析的操作数交由运算Endpoint报错
+// 授权在校验之前执行，不符合rat模式限制的操作数（超长、超大指数、进制前缀）不做高精度解析，按float64比较
+func withinLimit(n json.Number, limit int) bool {
+	s := string(n)
+	if checkOperand(services.ModeRat, s) != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) || math.IsNaN(v) {
+			return true
+		}
+		return math.Abs(v) <= float64(limit)
+	}
+	r, _ := new(big.Rat).SetString(s)
+	return r.Abs(r).Cmp(big.NewRat(int64(limit), 1)) <= 0
+}
+
+// AuthorizationError 授权失败
+type AuthorizationError struct {
+	Operation string   `json:"operation"`
+	Roles     []string `json:"roles"`
+	Scopes    []string `json:"scopes"`
+	Reason    string   `json:"reason"`
+}
+
+func (e *AuthorizationError) Error() string {
+	return fmt.Sprintf("operation %s is not permitted: %s", e.Operation, e.Reason)
+}
+
+func (e *AuthorizationError) ErrorCode() apperrors.Code {
+	return apperrors.PermissionDenied
+}
+
+func (e *AuthorizationError) ErrorDetails() interface{} {
+	return e
+}
+
+// NewAuthorizationMiddleware 创建授权中间件，需放在身份认证中间件之后
+// registry用于将运算别名归一为标准名称；表达式计算请求在计算时按其中的每次二元运算分别授权
+func NewAuthorizationMiddleware(policy Policy, registry *Registry) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			switch req := request.(type) {
+			case ArithmeticRequest:
+				claims, ok := services.ClaimsFromContext(ctx)
+				if !ok {
+					return nil, services.ErrTokenContextMissing
+				}
+				if err := policy.authorize(registry, claims, req); err != nil {
+					return nil, err
+				}
+			case EvaluateRequest:
+				claims, ok := services.ClaimsFromContext(ctx)
+				if !ok {
+					return nil, services.ErrTokenContextMissing
+				}
+				// 解析错误、除数为零等由计算过程返回，除数为零的除法先经过授权
+				req.check = func(op services.ExprOperation) error {
+					return policy.authorize(registry, claims, ArithmeticRequest{
+						RequestType: op.Op,
+						A:           formatOperand(op.A),
+						B:           formatOperand(op.B),
+					})
+				}
+				request = req
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// authorize 按策略检查单次运算，未注册的运算交由运算Endpoint返回可用的运算列表
+func (p Policy) authorize(registry *Registry, claims *services.ArithmeticCustomClaims, req ArithmeticRequest) error {
+	if _, err := registry.Lookup(req.RequestType); err != nil {
+		return nil
+	}
+
+	perms := p.permissions(registry, req.RequestType)
+	reason := "insufficient role or scope"
+	if len(perms) == 0 {
+		reason = "no policy for operation"
+	}
+	for _, perm := range perms {
+		if !perm.matches(claims) {
+			continue
+		}
+		if perm.inRange(req) {
+			return nil
+		}
+		reason = fmt.Sprintf("operands exceed %d for this role", perm.MaxOperand)
+	}
+	return &AuthorizationError{
+		Operation: req.RequestType,
+		Roles:     claims.Roles,
+		Scopes:    claims.Scopes,
+		Reason:    reason,
+	}
+}
+
+// formatOperand 将表达式中的操作数转换为json.Number，无穷大和NaN没有对应的数字，视为超出任何上限
+func formatOperand(v float64) json.Number {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return json.Number(strconv.FormatFloat(math.MaxFloat64, 'g', -1, 64))
+	}
+	return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+}
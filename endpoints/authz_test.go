@@ -0,0 +1,144 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"learn/services"
+	"strings"
+	"testing"
+)
+
+// authorizeAs 以claims的身份通过授权中间件调用，返回是否到达endpoint
+func authorizeAs(policy Policy, claims *services.ArithmeticCustomClaims, request interface{}) (bool, error) {
+	reached := false
+	next := func(ctx context.Context, request interface{}) (interface{}, error) {
+		reached = true
+		return nil, nil
+	}
+	ctx := context.Background()
+	if claims != nil {
+		ctx = context.WithValue(ctx, services.JWTClaimsContextKey, claims)
+	}
+	_, err := NewAuthorizationMiddleware(policy, DefaultRegistry())(next)(ctx, request)
+	return reached, err
+}
+
+func TestAuthorization(t *testing.T) {
+	reader := &services.ArithmeticCustomClaims{Roles: []string{"reader"}}
+	power := &services.ArithmeticCustomClaims{Roles: []string{"power"}}
+	scoped := &services.ArithmeticCustomClaims{Scopes: []string{"arithmetic:all"}}
+	nobody := &services.ArithmeticCustomClaims{}
+	tests := []struct {
+		name   string
+		claims *services.ArithmeticCustomClaims
+		req    ArithmeticRequest
+		reason string
+	}{
+		{"reader adds", reader, ArithmeticRequest{RequestType: "Add", A: "1", B: "2"}, ""},
+		{"case insensitive", reader, ArithmeticRequest{RequestType: "add", A: "1", B: "2"}, ""},
+		{"alias", reader, ArithmeticRequest{RequestType: "Mul", A: "2", B: "3"}, ""},
+		{"alias denied", reader, ArithmeticRequest{RequestType: "Div", A: "6", B: "3"}, "insufficient role or scope"},
+		{"reader multiplies small numbers", reader, ArithmeticRequest{RequestType: "Multiply", A: "1000", B: "-1000"}, ""},
+		{"power multiplies large numbers", power, ArithmeticRequest{RequestType: "Multiply", A: "5000", B: "2"}, ""},
+		{"scope grants divide", scoped, ArithmeticRequest{RequestType: "Divide", A: "6", B: "3"}, ""},
+		{"role denied", reader, ArithmeticRequest{RequestType: "Divide", A: "6", B: "3"}, "insufficient role or scope"},
+		{"scope denied", nobody, ArithmeticRequest{RequestType: "Add", A: "1", B: "2"}, "insufficient role or scope"},
+		{"max operand exceeded", reader, ArithmeticRequest{RequestType: "Multiply", A: "1001", B: "2"}, "operands exceed 1000 for this role"},
+		{"negative operand exceeded", reader, ArithmeticRequest{RequestType: "Multiply", A: "2", B: "-1001"}, "operands exceed 1000 for this role"},
+		{"long operand exceeded", reader, ArithmeticRequest{RequestType: "Multiply", A: json.Number("1" + strings.Repeat("0", 300)), B: "2"}, "operands exceed 1000 for this role"},
+		{"huge exponent exceeded", reader, ArithmeticRequest{RequestType: "Multiply", A: "1e1000000000", B: "2"}, "operands exceed 1000 for this role"},
+		{"hex float exceeded", reader, ArithmeticRequest{RequestType: "Multiply", A: "0x1p20", B: "2"}, "operands exceed 1000 for this role"},
+		{"invalid operand left to validation", reader, ArithmeticRequest{RequestType: "Multiply", A: "0x10", B: "2"}, ""},
+	}
+	for _, tt := range tests {
+		reached, err := authorizeAs(DefaultPolicy(), tt.claims, tt.req)
+		if tt.reason == "" {
+			if err != nil || !reached {
+				t.Errorf("%s: error = %v, want the request to reach the endpoint", tt.name, err)
+			}
+			continue
+		}
+		var authErr *AuthorizationError
+		if !errors.As(err, &authErr) || authErr.Reason != tt.reason || reached {
+			t.Errorf("%s: error = %v, want denied with %q", tt.name, err, tt.reason)
+		}
+	}
+}
+
+func TestAuthorizationUnknownOperation(t *testing.T) {
+	power := &services.ArithmeticCustomClaims{Roles: []string{"power"}}
+	// 未注册的运算交由运算Endpoint返回可用的运算列表
+	if reached, err := authorizeAs(DefaultPolicy(), power, ArithmeticRequest{RequestType: "Shift", A: "1", B: "2"}); err != nil || !reached {
+		t.Errorf("unregistered operation: error = %v, want it passed to the endpoint", err)
+	}
+	// 已注册但策略中没有的运算一律拒绝
+	reached, err := authorizeAs(Policy{}, power, ArithmeticRequest{RequestType: "Add", A: "1", B: "2"})
+	var authErr *AuthorizationError
+	if !errors.As(err, &authErr) || authErr.Reason != "no policy for operation" || reached {
+		t.Errorf("operation without policy: error = %v, want denied", err)
+	}
+}
+
+func TestAuthorizationWithoutClaims(t *testing.T) {
+	if _, err := authorizeAs(DefaultPolicy(), nil, ArithmeticRequest{RequestType: "Add"}); err != services.ErrTokenContextMissing {
+		t.Errorf("error = %v, want ErrTokenContextMissing", err)
+	}
+	// 其他类型的请求不做授权
+	if reached, err := authorizeAs(DefaultPolicy(), nil, "health"); err != nil || !reached {
+		t.Errorf("other request: error = %v, want it passed through", err)
+	}
+}
+
+// evaluateAs 以claims的身份经过授权中间件计算表达式
+func evaluateAs(claims *services.ArithmeticCustomClaims, expr string) (interface{}, error) {
+	ctx := context.WithValue(context.Background(), services.JWTClaimsContextKey, claims)
+	e := NewAuthorizationMiddleware(DefaultPolicy(), DefaultRegistry())(MakeEvaluateEndpoint(services.ArithmeticService{}))
+	return e(ctx, EvaluateRequest{Expr: expr, Vars: map[string]float64{"x": 1}})
+}
+
+func TestAuthorizeEvaluate(t *testing.T) {
+	reader := &services.ArithmeticCustomClaims{Roles: []string{"reader"}}
+	power := &services.ArithmeticCustomClaims{Roles: []string{"power"}}
+	tests := []struct {
+		claims *services.ArithmeticCustomClaims
+		expr   string
+		want   string
+		denied string
+	}{
+		{reader, "1 + 2 - x", "2", ""},
+		{reader, "-(3 * 4)", "-12", ""},
+		{reader, "(1 + 2) / 3", "", services.OpDivide},
+		{reader, "2 ^ 10", "", services.OpPow},
+		{reader, "2 * 500", "1000", ""},
+		{reader, "2 * (600 + 600)", "", services.OpMultiply},
+		{reader, "1 / 0", "", services.OpDivide},
+		{reader, "(1 + 2) * 3 +", "", ""},
+		{power, "(1 + 5) / 2 ^ 2 * 5000", "7500", ""},
+	}
+	for _, tt := range tests {
+		response, err := evaluateAs(tt.claims, tt.expr)
+		var authErr *AuthorizationError
+		if tt.denied != "" {
+			if !errors.As(err, &authErr) || authErr.Operation != tt.denied {
+				t.Errorf("%v %q: error = %v, want %s to be denied", tt.claims.Roles, tt.expr, err, tt.denied)
+			}
+			continue
+		}
+		if errors.As(err, &authErr) {
+			t.Errorf("%v %q: error = %v, want it authorized", tt.claims.Roles, tt.expr, err)
+			continue
+		}
+		// 授权通过但无法解析的表达式返回解析错误
+		if tt.want == "" {
+			var perr *services.ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("%v %q: error = %v, want a ParseError", tt.claims.Roles, tt.expr, err)
+			}
+			continue
+		}
+		if err != nil || response.(EvaluateResponse).Result != json.Number(tt.want) {
+			t.Errorf("%v %q = %v, %v, want %s", tt.claims.Roles, tt.expr, response, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"learn/apperrors"
+	"sync"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// MaxBatchSize 单次批量请求的最大条目数
+const MaxBatchSize = 100
+
+var ErrBatchTooLarge = apperrors.New(apperrors.InvalidArgument, "batch size exceeds limit")
+
+// BatchRequest 批量运算请求，Concurrency为并发计算的条目数，默认逐条计算
+type BatchRequest struct {
+	Items       []ArithmeticRequest
+	Concurrency int
+}
+
+// Weight 限流时按条目数计费
+func (r BatchRequest) Weight() int {
+	return len(r.Items)
+}
+
+// BatchItemResponse 单个条目的运算结果
+type BatchItemResponse struct {
+	Index    int              `json:"index"`
+	Result   json.Number      `json:"result,omitempty"`
+	Mode     string           `json:"mode,omitempty"`
+	Rational string           `json:"rational,omitempty"`
+	Error    *apperrors.Error `json:"error,omitempty"`
+}
+
+// BatchResponse 批量运算响应，结果顺序与请求一致
+type BatchResponse struct {
+	Results []BatchItemResponse `json:"results"`
+}
+
+// MakeBatchEndpoint 创建批量运算Endpoint
+// calculate为单条运算Endpoint（含授权），maxConcurrency限制每个批量请求的并发数
+func MakeBatchEndpoint(calculate endpoint.Endpoint, maxConcurrency int) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(BatchRequest)
+		if len(req.Items) > MaxBatchSize {
+			return nil, ErrBatchTooLarge
+		}
+
+		workers := req.Concurrency
+		if workers > maxConcurrency {
+			workers = maxConcurrency
+		}
+		if workers < 1 {
+			workers = 1
+		}
+
+		results := make([]BatchItemResponse, len(req.Items))
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					// 请求已取消或超时后不再计算，剩余条目返回ctx的错误
+					if err := ctx.Err(); err != nil {
+						results[i] = BatchItemResponse{Index: i, Error: apperrors.From(apperrors.Wrap(apperrors.Unavailable, err))}
+						continue
+					}
+					results[i] = calculateItem(ctx, calculate, i, req.Items[i])
+				}
+			}()
+		}
+		for i := range req.Items {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+
+		return BatchResponse{Results: results}, nil
+	}
+}
+
+func calculateItem(ctx context.Context, calculate endpoint.Endpoint, index int, item ArithmeticRequest) BatchItemResponse {
+	resp, err := calculate(ctx, item)
+	if err != nil {
+		return BatchItemResponse{Index: index, Error: apperrors.From(err)}
+	}
+
+	res := resp.(ArithmeticResponse)
+	return BatchItemResponse{
+		Index:    index,
+		Result:   res.Result,
+		Mode:     res.Mode,
+		Rational: res.Rational,
+	}
+}
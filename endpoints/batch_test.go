@@ -0,0 +1,41 @@
+package endpoints
+
+import (
+	"context"
+	"learn/apperrors"
+	"testing"
+)
+
+func TestBatchStopsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	calculate := func(ctx context.Context, request interface{}) (interface{}, error) {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return ArithmeticResponse{Result: "1"}, nil
+	}
+	items := make([]ArithmeticRequest, 5)
+
+	resp, err := MakeBatchEndpoint(calculate, 1)(ctx, BatchRequest{Items: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("calculated %d items, want 2 before the cancel", calls)
+	}
+	results := resp.(BatchResponse).Results
+	for i, res := range results {
+		if i < 2 {
+			if res.Error != nil || res.Result != "1" {
+				t.Errorf("item %d = %+v, want a result", i, res)
+			}
+			continue
+		}
+		if res.Index != i || res.Error == nil || res.Error.Code != apperrors.Unavailable || res.Error.Message != context.Canceled.Error() {
+			t.Errorf("item %d = %+v, want the context error", i, res)
+		}
+	}
+}
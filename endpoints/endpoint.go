@@ -2,30 +2,49 @@ package endpoints
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	"learn/apperrors"
+	"learn/healths"
 	"learn/services"
-	"strings"
+	"strconv"
 
 	"github.com/go-kit/kit/endpoint"
 )
 
-var ErrInvalidRequestType = errors.New("错误！")
+var (
+	ErrInvalidRequestType = apperrors.New(apperrors.InvalidArgument, "request_type is required")
+	ErrInvalidMode        = apperrors.New(apperrors.InvalidArgument, "invalid numeric mode")
+	ErrInvalidOperand     = apperrors.New(apperrors.InvalidArgument, "invalid operand")
+)
 
+// ArithmeticRequest 运算请求，Mode为空时按int模式计算
 type ArithmeticRequest struct {
-	RequestType string `json:"request_type"`
-	A           int    `json:"a"`
-	B           int    `json:"b"`
+	RequestType string      `json:"request_type"`
+	Mode        string      `json:"mode,omitempty"`
+	A           json.Number `json:"a"`
+	B           json.Number `json:"b,omitempty"`
 }
 
+// ArithmeticResponse 运算响应，rat模式下Rational为精确的分数形式
+// 运算失败时Endpoint直接返回错误，由传输层编码为统一的错误响应
 type ArithmeticResponse struct {
-	Result int   `json:"Result"`
-	Error  error `json:"error"`
+	Result   json.Number `json:"Result"`
+	Mode     string      `json:"mode,omitempty"`
+	Rational string      `json:"rational,omitempty"`
 }
 
 type ArithmeticEndpoints struct {
 	ArithmeticEndpoint  endpoint.Endpoint
+	OperationsEndpoint  endpoint.Endpoint
+	EvaluateEndpoint    endpoint.Endpoint
+	BatchEndpoint       endpoint.Endpoint
 	HealthCheckEndpoint endpoint.Endpoint
+	LivenessEndpoint    endpoint.Endpoint
+	ReadinessEndpoint   endpoint.Endpoint
 	AuthEndpoint        endpoint.Endpoint
+	RefreshEndpoint     endpoint.Endpoint
+	LogoutEndpoint      endpoint.Endpoint
+	JWKSEndpoint        endpoint.Endpoint
 }
 
 // HealthRequest 健康检查请求结构
@@ -45,31 +64,78 @@ func MakeHealthCheckEndpoint(svc services.Service) endpoint.Endpoint {
 }
 
 // MakeArithmeticEndpoint make endpoint
-func MakeArithmeticEndpoint(svc services.Service) endpoint.Endpoint {
+// 运算通过registry分发，未知运算返回UnknownOperationError，参数不合法时返回ValidationError
+func MakeArithmeticEndpoint(registry *Registry) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(ArithmeticRequest)
+		if req.RequestType == "" {
+			verr := &ValidationError{}
+			verr.Add("request_type", "is required")
+			return nil, verr
+		}
 
-		var (
-			res, a, b int
-			calError  error
-		)
-
-		a = req.A
-		b = req.B
-
-		if strings.EqualFold(req.RequestType, "Add") {
-			res = svc.Add(a, b)
-		} else if strings.EqualFold(req.RequestType, "Substract") {
-			res = svc.Subtract(a, b)
-		} else if strings.EqualFold(req.RequestType, "Multiply") {
-			res = svc.Multiply(a, b)
-		} else if strings.EqualFold(req.RequestType, "Divide") {
-			res, calError = svc.Divide(a, b)
-		} else {
-			return nil, ErrInvalidRequestType
+		op, err := registry.Lookup(req.RequestType)
+		if err != nil {
+			return nil, err
 		}
 
-		return ArithmeticResponse{Result: res, Error: calError}, nil
+		mode, err := validateArithmeticRequest(op, req)
+		if err != nil {
+			return nil, err
+		}
+		return op.Apply(mode, []json.Number{req.A, req.B}[:op.Arity])
+	}
+}
+
+// OperationsRequest 运算列表请求
+type OperationsRequest struct{}
+
+// OperationsResponse 运算列表响应
+type OperationsResponse struct {
+	Operations []Operation `json:"operations"`
+}
+
+// MakeOperationsEndpoint 返回注册表中的所有运算，供客户端查询支持的运算
+func MakeOperationsEndpoint(registry *Registry) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		return OperationsResponse{Operations: registry.Operations()}, nil
+	}
+}
+
+// HealthReportRequest 存活或就绪检查请求
+type HealthReportRequest struct{}
+
+// MakeHealthReportEndpoint 执行注册表中kind类型的检查，返回healths.Report
+// 检查失败不作为错误返回，由传输层根据Report.Status设置状态码
+func MakeHealthReportEndpoint(registry *healths.Registry, kind healths.Kind) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		return registry.Run(ctx, kind), nil
+	}
+}
+
+// EvaluateRequest 表达式计算请求，Vars为表达式中使用的变量
+type EvaluateRequest struct {
+	Expr string             `json:"expr"`
+	Vars map[string]float64 `json:"vars,omitempty"`
+	// check 由授权中间件设置，计算时检查每次二元运算
+	check services.ExprCheck
+}
+
+// EvaluateResponse 表达式计算响应
+type EvaluateResponse struct {
+	Result json.Number `json:"result"`
+}
+
+// MakeEvaluateEndpoint 创建表达式计算Endpoint
+func MakeEvaluateEndpoint(svc services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(EvaluateRequest)
+
+		res, err := svc.Evaluate(req.Expr, req.Vars, req.check)
+		if err != nil {
+			return nil, err
+		}
+		return EvaluateResponse{Result: json.Number(strconv.FormatFloat(res, 'g', -1, 64))}, nil
 	}
 }
 
@@ -79,11 +145,24 @@ type AuthRequest struct {
 	Pwd  string `json:"pwd"`
 }
 
-// AuthResponse
+// AuthResponse 登录失败时Endpoint返回身份认证错误
 type AuthResponse struct {
-	Success bool   `json:"success"`
-	Token   string `json:"token"`
-	Error   string `json:"error"`
+	Success      bool   `json:"success"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+}
+
+func makeAuthResponse(token services.Token, err error) (AuthResponse, error) {
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	return AuthResponse{
+		Success:      true,
+		Token:        token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresIn:    token.ExpiresIn,
+	}, nil
 }
 
 func MakeAuthEndpoint(svc services.Service) endpoint.Endpoint {
@@ -91,21 +170,63 @@ func MakeAuthEndpoint(svc services.Service) endpoint.Endpoint {
 		req := request.(AuthRequest)
 
 		token, err := svc.Login(req.Name, req.Pwd)
+		return makeAuthResponse(token, err)
+	}
+}
 
-		var resp AuthResponse
-		if err != nil {
-			resp = AuthResponse{
-				Success: err == nil,
-				Token:   token,
-				Error:   err.Error(),
+// RefreshRequest 刷新token请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// MakeRefreshEndpoint 使用刷新token换取新的访问token
+func MakeRefreshEndpoint(svc services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(RefreshRequest)
+
+		token, err := svc.Refresh(req.RefreshToken)
+		return makeAuthResponse(token, err)
+	}
+}
+
+// LogoutRequest 注销请求，请求头中的访问token也会一并吊销
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutResponse 注销响应
+type LogoutResponse struct {
+	Success bool `json:"success"`
+}
+
+// MakeLogoutEndpoint 吊销刷新token及当前访问token
+func MakeLogoutEndpoint(svc services.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(LogoutRequest)
+
+		tokens := []string{req.RefreshToken}
+		if access, ok := ctx.Value(services.JWTTokenContextKey).(string); ok {
+			tokens = append(tokens, access)
+		}
+
+		for _, token := range tokens {
+			if token == "" {
+				continue
 			}
-		} else {
-			resp = AuthResponse{
-				Success: err == nil,
-				Token:   token,
+			if err := svc.Logout(token); err != nil {
+				return nil, err
 			}
 		}
+		return LogoutResponse{Success: true}, nil
+	}
+}
+
+// JWKSRequest 公钥查询请求
+type JWKSRequest struct{}
 
-		return resp, nil
+// MakeJWKSEndpoint 返回验证token所需的公钥集合
+func MakeJWKSEndpoint(tokens *services.TokenManager) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		return tokens.JWKS(), nil
 	}
 }
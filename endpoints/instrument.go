@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+)
+
+// OperationLogging 记录每次运算的参数、结果和耗时，对所有注册的运算生效
+func OperationLogging(logger log.Logger) OperationMiddleware {
+	return func(op Operation, next ApplyFunc) ApplyFunc {
+		return func(mode string, operands []json.Number) (resp ArithmeticResponse, err error) {
+			defer func(begin time.Time) {
+				logger.Log(
+					"function", op.Name,
+					"mode", mode,
+					"operands", operands,
+					"result", resp.Result,
+					"err", err,
+					"took", time.Since(begin),
+				)
+			}(time.Now())
+			return next(mode, operands)
+		}
+	}
+}
+
+// OperationMetrics 按运算名称统计请求数和耗时，标签与服务方法的指标一致
+func OperationMetrics(requestCount metrics.Counter, requestLatency metrics.Histogram) OperationMiddleware {
+	return func(op Operation, next ApplyFunc) ApplyFunc {
+		lvs := []string{"method", op.Name}
+		return func(mode string, operands []json.Number) (ArithmeticResponse, error) {
+			defer func(begin time.Time) {
+				requestCount.With(lvs...).Add(1)
+				requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+			}(time.Now())
+			return next(mode, operands)
+		}
+	}
+}
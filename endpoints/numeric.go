@@ -0,0 +1,89 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+)
+
+func parseInts(operands []json.Number) ([]int, error) {
+	n := make([]int, len(operands))
+	for i, operand := range operands {
+		v, err := strconv.Atoi(string(operand))
+		if err != nil {
+			return nil, ErrInvalidOperand
+		}
+		n[i] = v
+	}
+	return n, nil
+}
+
+func parseInt64s(operands []json.Number) ([]int64, error) {
+	n := make([]int64, len(operands))
+	for i, operand := range operands {
+		v, err := strconv.ParseInt(string(operand), 10, 64)
+		if err != nil {
+			return nil, ErrInvalidOperand
+		}
+		n[i] = v
+	}
+	return n, nil
+}
+
+func parseFloat64s(operands []json.Number) ([]float64, error) {
+	n := make([]float64, len(operands))
+	for i, operand := range operands {
+		v, err := strconv.ParseFloat(string(operand), 64)
+		if err != nil {
+			return nil, ErrInvalidOperand
+		}
+		n[i] = v
+	}
+	return n, nil
+}
+
+// parseRats 解析有理数，支持整数、小数、科学计数法和"1/3"形式
+func parseRats(operands []json.Number) ([]*big.Rat, error) {
+	n := make([]*big.Rat, len(operands))
+	for i, operand := range operands {
+		v, ok := new(big.Rat).SetString(string(operand))
+		if !ok {
+			return nil, ErrInvalidOperand
+		}
+		n[i] = v
+	}
+	return n, nil
+}
+
+// ratPrecision 无限小数转换为十进制时保留的位数
+const ratPrecision = 20
+
+// formatRat 将有理数格式化为十进制数，有限小数精确表示，无限小数保留ratPrecision位
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// 分母只含因子2和5时为有限小数，小数位数为两者指数的较大值
+	d := new(big.Int).Set(r.Denom())
+	digits := 0
+	for _, p := range []int64{2, 5} {
+		n := 0
+		q, m := new(big.Int), new(big.Int)
+		for {
+			q.QuoRem(d, big.NewInt(p), m)
+			if m.Sign() != 0 {
+				break
+			}
+			d.Set(q)
+			n++
+		}
+		if n > digits {
+			digits = n
+		}
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		digits = ratPrecision
+	}
+	return r.FloatString(digits)
+}
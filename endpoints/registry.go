@@ -0,0 +1,262 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"learn/apperrors"
+	"learn/services"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation 运算定义
+type Operation struct {
+	Name        string   `json:"name" xml:"name,attr"`
+	Aliases     []string `json:"aliases,omitempty" xml:"alias"`
+	Arity       int      `json:"arity" xml:"arity,attr"`
+	Modes       []string `json:"modes" xml:"mode"`
+	Description string   `json:"description" xml:"description"`
+
+	// Apply 运算的实现
+	Apply ApplyFunc `json:"-" xml:"-"`
+}
+
+// ApplyFunc 执行运算，mode已校验，operands长度与运算的Arity一致
+type ApplyFunc func(mode string, operands []json.Number) (ArithmeticResponse, error)
+
+// OperationMiddleware 包装运算的实现，用于日志、监控等对所有运算统一的处理
+type OperationMiddleware func(op Operation, next ApplyFunc) ApplyFunc
+
+// SupportsMode 判断运算是否支持该数值模式
+func (op Operation) SupportsMode(mode string) bool {
+	for _, m := range op.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownOperationError 未知的运算类型，Valid为所有可用的运算名称
+type UnknownOperationError struct {
+	Name  string   `json:"name"`
+	Valid []string `json:"valid"`
+}
+
+func (e *UnknownOperationError) Error() string {
+	return fmt.Sprintf("unknown operation %q, valid operations: %s", e.Name, strings.Join(e.Valid, ", "))
+}
+
+func (e *UnknownOperationError) ErrorCode() apperrors.Code {
+	return apperrors.NotFound
+}
+
+func (e *UnknownOperationError) ErrorDetails() interface{} {
+	return e
+}
+
+// Registry 运算注册表，名称和别名不区分大小写
+type Registry struct {
+	ops   []Operation
+	index map[string]int
+	mws   []OperationMiddleware
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{index: make(map[string]int)}
+}
+
+// Register 注册运算，名称或别名重复时返回错误
+func (r *Registry) Register(op Operation) error {
+	if op.Name == "" || op.Apply == nil || op.Arity < 1 || op.Arity > 2 || len(op.Modes) == 0 {
+		return fmt.Errorf("operation %q: name, arity (1 or 2), modes and apply are required", op.Name)
+	}
+	names := append([]string{op.Name}, op.Aliases...)
+	for _, name := range names {
+		if _, ok := r.index[strings.ToLower(name)]; ok {
+			return fmt.Errorf("operation name %q is already registered", name)
+		}
+	}
+
+	for _, mw := range r.mws {
+		op.Apply = mw(op, op.Apply)
+	}
+	r.ops = append(r.ops, op)
+	for _, name := range names {
+		r.index[strings.ToLower(name)] = len(r.ops) - 1
+	}
+	return nil
+}
+
+// Use 为已注册和之后注册的所有运算添加中间件，后添加的在外层
+func (r *Registry) Use(mw OperationMiddleware) {
+	r.mws = append(r.mws, mw)
+	for i, op := range r.ops {
+		r.ops[i].Apply = mw(op, op.Apply)
+	}
+}
+
+// Lookup 根据名称或别名查询运算
+func (r *Registry) Lookup(name string) (Operation, error) {
+	i, ok := r.index[strings.ToLower(name)]
+	if !ok {
+		return Operation{}, &UnknownOperationError{Name: name, Valid: r.Names()}
+	}
+	return r.ops[i], nil
+}
+
+// Names 返回所有运算的标准名称，按字母排序
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.ops))
+	for _, op := range r.ops {
+		names = append(names, op.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Operations 返回所有运算，按注册顺序
+func (r *Registry) Operations() []Operation {
+	ops := make([]Operation, len(r.ops))
+	copy(ops, r.ops)
+	return ops
+}
+
+// DefaultRegistry 注册所有内置运算
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, op := range []Operation{
+		basicOperation(services.OpAdd, nil, "a + b", func(a, b int) (int, error) {
+			return a + b, nil
+		}),
+		basicOperation(services.OpSubtract, []string{"Substract", "Sub"}, "a - b", func(a, b int) (int, error) {
+			return a - b, nil
+		}),
+		basicOperation(services.OpMultiply, []string{"Mul"}, "a * b", func(a, b int) (int, error) {
+			return a * b, nil
+		}),
+		basicOperation(services.OpDivide, []string{"Div"}, "a / b, truncated in int modes", func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, services.ErrDivideByZero
+			}
+			return a / b, nil
+		}),
+		intOperation(services.OpMod, []string{"Rem"}, 2, "a % b, sign follows a", func(n []int) (int, error) {
+			return services.Mod(n[0], n[1])
+		}),
+		intOperation(services.OpPow, []string{"Power"}, 2, "a raised to a non-negative power b", func(n []int) (int, error) {
+			return services.Pow(n[0], n[1])
+		}),
+		intOperation(services.OpGcd, nil, 2, "greatest common divisor", func(n []int) (int, error) {
+			return services.Gcd(n[0], n[1])
+		}),
+		intOperation(services.OpLcm, nil, 2, "least common multiple", func(n []int) (int, error) {
+			return services.Lcm(n[0], n[1])
+		}),
+		intOperation(services.OpFactorial, []string{"Fact"}, 1, "a!", func(n []int) (int, error) {
+			return services.Factorial(n[0])
+		}),
+		{
+			Name:        services.OpSqrt,
+			Arity:       1,
+			Modes:       []string{services.ModeInt},
+			Description: "square root of a non-negative a",
+			Apply: func(mode string, operands []json.Number) (ArithmeticResponse, error) {
+				n, err := parseInts(operands)
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				root, err := services.Sqrt(n[0])
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				return ArithmeticResponse{Result: json.Number(strconv.FormatFloat(root, 'g', -1, 64))}, nil
+			},
+		},
+	} {
+		if err := r.Register(op); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// basicOperation 支持所有数值模式的二元运算，int模式使用intFn，其余模式使用services.Calculate*
+func basicOperation(name string, aliases []string, desc string, intFn func(a, b int) (int, error)) Operation {
+	return Operation{
+		Name:        name,
+		Aliases:     aliases,
+		Arity:       2,
+		Modes:       []string{services.ModeInt, services.ModeInt64, services.ModeFloat64, services.ModeRat},
+		Description: desc,
+		Apply: func(mode string, operands []json.Number) (ArithmeticResponse, error) {
+			switch mode {
+			case services.ModeInt64:
+				n, err := parseInt64s(operands)
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				res, err := services.CalculateInt64(name, n[0], n[1])
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				return ArithmeticResponse{Result: json.Number(strconv.FormatInt(res, 10)), Mode: mode}, nil
+			case services.ModeFloat64:
+				n, err := parseFloat64s(operands)
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				res, err := services.CalculateFloat64(name, n[0], n[1])
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				return ArithmeticResponse{Result: json.Number(strconv.FormatFloat(res, 'g', -1, 64)), Mode: mode}, nil
+			case services.ModeRat:
+				n, err := parseRats(operands)
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				res, err := services.CalculateRat(name, n[0], n[1])
+				if err != nil {
+					return ArithmeticResponse{}, err
+				}
+				return ArithmeticResponse{Result: json.Number(formatRat(res)), Mode: mode, Rational: res.RatString()}, nil
+			}
+
+			// int模式，溢出时结果回绕
+			n, err := parseInts(operands)
+			if err != nil {
+				return ArithmeticResponse{}, err
+			}
+			res, err := intFn(n[0], n[1])
+			if err != nil {
+				return ArithmeticResponse{}, err
+			}
+			return ArithmeticResponse{Result: json.Number(strconv.Itoa(res))}, nil
+		},
+	}
+}
+
+// intOperation 仅支持int模式的运算
+func intOperation(name string, aliases []string, arity int, desc string, fn func(n []int) (int, error)) Operation {
+	return Operation{
+		Name:        name,
+		Aliases:     aliases,
+		Arity:       arity,
+		Modes:       []string{services.ModeInt},
+		Description: desc,
+		Apply: func(mode string, operands []json.Number) (ArithmeticResponse, error) {
+			n, err := parseInts(operands)
+			if err != nil {
+				return ArithmeticResponse{}, err
+			}
+			res, err := fn(n)
+			if err != nil {
+				return ArithmeticResponse{}, err
+			}
+			return ArithmeticResponse{Result: json.Number(strconv.Itoa(res))}, nil
+		},
+	}
+}
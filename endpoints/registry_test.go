@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"learn/services"
+	"testing"
+)
+
+func TestRegistryUse(t *testing.T) {
+	r := DefaultRegistry()
+	var calls []string
+	r.Use(func(op Operation, next ApplyFunc) ApplyFunc {
+		return func(mode string, operands []json.Number) (ArithmeticResponse, error) {
+			calls = append(calls, op.Name)
+			return next(mode, operands)
+		}
+	})
+	echo := Operation{
+		Name:  "Echo",
+		Arity: 1,
+		Modes: []string{services.ModeInt},
+		Apply: func(mode string, operands []json.Number) (ArithmeticResponse, error) {
+			return ArithmeticResponse{Result: operands[0]}, nil
+		},
+	}
+	if err := r.Register(echo); err != nil {
+		t.Fatal(err)
+	}
+
+	calculate := MakeArithmeticEndpoint(r)
+	for _, req := range []ArithmeticRequest{
+		{RequestType: "sub", A: "5", B: "3"},
+		{RequestType: "Echo", A: "2"},
+	} {
+		if _, err := calculate(context.Background(), req); err != nil {
+			t.Fatalf("%s: %v", req.RequestType, err)
+		}
+	}
+	if len(calls) != 2 || calls[0] != services.OpSubtract || calls[1] != "Echo" {
+		t.Errorf("middleware saw %v, want [Subtract Echo]", calls)
+	}
+}
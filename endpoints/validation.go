@@ -0,0 +1,146 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learn/apperrors"
+	"learn/services"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// rat模式下操作数的长度和指数上限，避免超大数字耗尽内存
+const (
+	maxRatOperandLength = 256
+	maxRatExponent      = 1000
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError 请求校验失败，Fields包含所有不合法的字段
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// Add 记录字段错误
+func (e *ValidationError) Add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err 没有字段错误时返回nil
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) ErrorCode() apperrors.Code {
+	return apperrors.InvalidArgument
+}
+
+func (e *ValidationError) ErrorDetails() interface{} {
+	return e
+}
+
+// Is 按字段匹配ErrInvalidRequestType、ErrInvalidMode和ErrInvalidOperand
+func (e *ValidationError) Is(target error) bool {
+	for _, f := range e.Fields {
+		switch {
+		case f.Field == "request_type" && target == ErrInvalidRequestType,
+			f.Field == "mode" && target == ErrInvalidMode,
+			(f.Field == "a" || f.Field == "b") && target == ErrInvalidOperand:
+			return true
+		}
+	}
+	return false
+}
+
+// validateArithmeticRequest 校验数值模式和操作数，返回规范化后的模式
+func validateArithmeticRequest(op Operation, req ArithmeticRequest) (string, error) {
+	verr := &ValidationError{}
+
+	mode := strings.ToLower(req.Mode)
+	if mode == "" {
+		mode = services.ModeInt
+	}
+	if !op.SupportsMode(mode) {
+		verr.Add("mode", "%q is not supported by %s, expected one of %s", req.Mode, op.Name, strings.Join(op.Modes, ", "))
+		return mode, verr
+	}
+
+	for i, field := range []string{"a", "b"} {
+		operand := []json.Number{req.A, req.B}[i]
+		switch {
+		case i >= op.Arity:
+			if operand != "" {
+				verr.Add(field, "%s takes %d operand(s)", op.Name, op.Arity)
+			}
+		case operand == "":
+			verr.Add(field, "is required")
+		default:
+			if msg := checkOperand(mode, string(operand)); msg != "" {
+				verr.Add(field, "%s", msg)
+			}
+		}
+	}
+	return mode, verr.Err()
+}
+
+// checkOperand 按数值模式检查操作数的格式和范围，合法时返回空字符串
+func checkOperand(mode, s string) string {
+	switch mode {
+	case services.ModeInt, services.ModeInt64:
+		bits := 64
+		if mode == services.ModeInt {
+			bits = strconv.IntSize
+		}
+		if _, err := strconv.ParseInt(s, 10, bits); err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return fmt.Sprintf("%q is out of range for %s", s, mode)
+			}
+			return fmt.Sprintf("%q is not a valid integer", s)
+		}
+	case services.ModeFloat64:
+		v, err := strconv.ParseFloat(s, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return fmt.Sprintf("%q is out of range for float64", s)
+		}
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprintf("%q is not a valid finite number", s)
+		}
+	case services.ModeRat:
+		if len(s) > maxRatOperandLength {
+			return fmt.Sprintf("must not exceed %d characters", maxRatOperandLength)
+		}
+		// 只接受十进制，big.Rat支持的0x、0b、0o前缀和p指数会绕过下面的指数上限
+		if strings.TrimLeft(s, "0123456789+-./eE") != "" {
+			return fmt.Sprintf("%q is not a decimal number or fraction", s)
+		}
+		if i := strings.IndexAny(s, "eE"); i >= 0 {
+			exp, err := strconv.Atoi(s[i+1:])
+			if errors.Is(err, strconv.ErrRange) || err == nil && (exp > maxRatExponent || exp < -maxRatExponent) {
+				return fmt.Sprintf("exponent must be within ±%d", maxRatExponent)
+			}
+		}
+		if _, ok := new(big.Rat).SetString(s); !ok {
+			return fmt.Sprintf("%q is not a valid rational number", s)
+		}
+	}
+	return ""
+}
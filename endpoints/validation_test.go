@@ -0,0 +1,80 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"learn/apperrors"
+	"strings"
+	"testing"
+)
+
+func TestValidateArithmeticRequest(t *testing.T) {
+	registry := DefaultRegistry()
+	tests := []struct {
+		name   string
+		req    ArithmeticRequest
+		mode   string
+		fields []string
+	}{
+		{"int", ArithmeticRequest{RequestType: "Add", A: "1", B: "-2"}, "int", nil},
+		{"mode is case insensitive", ArithmeticRequest{RequestType: "Add", Mode: "Float64", A: "1.5", B: "2e3"}, "float64", nil},
+		{"rat fraction", ArithmeticRequest{RequestType: "Divide", Mode: "rat", A: "1/3", B: "2.5e-3"}, "rat", nil},
+		{"unary", ArithmeticRequest{RequestType: "Sqrt", A: "9"}, "int", nil},
+		{"missing operands", ArithmeticRequest{RequestType: "Add"}, "int", []string{"a", "b"}},
+		{"extra operand", ArithmeticRequest{RequestType: "Sqrt", A: "9", B: "1"}, "int", []string{"b"}},
+		{"unsupported mode", ArithmeticRequest{RequestType: "Mod", Mode: "rat", A: "1", B: "2"}, "rat", []string{"mode"}},
+		{"not an integer", ArithmeticRequest{RequestType: "Add", A: "1.5", B: "x"}, "int", []string{"a", "b"}},
+		{"int64 overflow", ArithmeticRequest{RequestType: "Add", Mode: "int64", A: "9223372036854775808", B: "1"}, "int64", []string{"a"}},
+		{"float64 overflow", ArithmeticRequest{RequestType: "Add", Mode: "float64", A: "1e400", B: "1"}, "float64", []string{"a"}},
+		{"float64 nan", ArithmeticRequest{RequestType: "Add", Mode: "float64", A: "NaN", B: "Inf"}, "float64", []string{"a", "b"}},
+		{"rat too long", ArithmeticRequest{RequestType: "Add", Mode: "rat", A: json.Number(strings.Repeat("9", 257)), B: "1"}, "rat", []string{"a"}},
+		{"rat exponent", ArithmeticRequest{RequestType: "Add", Mode: "rat", A: "1e1001", B: "1E-1001"}, "rat", []string{"a", "b"}},
+		{"rat invalid", ArithmeticRequest{RequestType: "Add", Mode: "rat", A: "1/0", B: "1"}, "rat", []string{"a"}},
+		{"rat base prefix", ArithmeticRequest{RequestType: "Add", Mode: "rat", A: "0x10", B: "0b1/0o7"}, "rat", []string{"a", "b"}},
+		{"rat binary exponent", ArithmeticRequest{RequestType: "Add", Mode: "rat", A: "0x1p1000000", B: "1P3"}, "rat", []string{"a", "b"}},
+		{"rat exponent overflow", ArithmeticRequest{RequestType: "Add", Mode: "rat", A: "1e99999999999999999999", B: "1"}, "rat", []string{"a"}},
+	}
+	for _, tt := range tests {
+		op, err := registry.Lookup(tt.req.RequestType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mode, err := validateArithmeticRequest(op, tt.req)
+		if mode != tt.mode {
+			t.Errorf("%s: mode = %q, want %q", tt.name, mode, tt.mode)
+		}
+		var verr *ValidationError
+		if len(tt.fields) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: error = %v, want ValidationError", tt.name, err)
+			continue
+		}
+		var fields []string
+		for _, f := range verr.Fields {
+			fields = append(fields, f.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+			t.Errorf("%s: invalid fields %v, want %v", tt.name, fields, tt.fields)
+		}
+	}
+}
+
+func TestValidationErrorIs(t *testing.T) {
+	verr := &ValidationError{}
+	if verr.Err() != nil {
+		t.Error("Err() without fields should be nil")
+	}
+	verr.Add("a", "is required")
+	err := verr.Err()
+	if !errors.Is(err, ErrInvalidOperand) || errors.Is(err, ErrInvalidMode) {
+		t.Errorf("errors.Is mismatch for %v", err)
+	}
+	if code := apperrors.CodeOf(err); code != apperrors.InvalidArgument {
+		t.Errorf("code = %s, want %s", code, apperrors.InvalidArgument)
+	}
+}
@@ -1,31 +1,28 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"github.com/go-kit/kit/log"
 	"github.com/hashicorp/consul/api"
 	"github.com/openzipkin/zipkin-go"
 	zipkinhttpsvr "github.com/openzipkin/zipkin-go/middleware/http"
 	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"learn/configs"
 	"math/rand"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 )
 
 func main() {
 
-	// 创建环境变量
-	var (
-		consulHost = flag.String("consul.host", "192.168.192.146", "consul server ip address")
-		consulPort = flag.String("consul.port", "8500", "consul server port")
-		zipkinURL  = flag.String("zipkin.url", "http://192.168.192.146:9411/api/v2/spans", "Zipkin server url")
-	)
-	flag.Parse()
+	// 加载配置，与算术服务共用configs
+	conf := configs.MustLoad("gateway")
+	port := strconv.Itoa(conf.Gateway.Port)
 
 	//创建日志组件
 	var logger log.Logger
@@ -39,10 +36,10 @@ func main() {
 	{
 		var (
 			err           error
-			hostPort      = "localhost:9090"
+			hostPort      = "localhost:" + port
 			serviceName   = "gateway-service"
-			useNoopTracer = (*zipkinURL == "")
-			reporter      = zipkinhttp.NewReporter(*zipkinURL)
+			useNoopTracer = (conf.Zipkin.URL == "")
+			reporter      = zipkinhttp.NewReporter(conf.Zipkin.URL)
 		)
 		defer reporter.Close()
 		zEP, _ := zipkin.NewEndpoint(serviceName, hostPort)
@@ -54,13 +51,13 @@ func main() {
 			os.Exit(1)
 		}
 		if !useNoopTracer {
-			logger.Log("tracer", "Zipkin", "type", "Native", "URL", *zipkinURL)
+			logger.Log("tracer", "Zipkin", "type", "Native", "URL", conf.Zipkin.URL)
 		}
 	}
 
 	// 创建consul api客户端
 	consulConfig := api.DefaultConfig()
-	consulConfig.Address = "http://" + *consulHost + ":" + *consulPort
+	consulConfig.Address = "http://" + conf.Consul.Address()
 	consulClient, err := api.NewClient(consulConfig)
 	if err != nil {
 		logger.Log("err", err)
@@ -83,15 +80,15 @@ func main() {
 
 	errc := make(chan error)
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		errc <- fmt.Errorf("%s", <-c)
 	}()
 
 	//开始监听
 	go func() {
-		logger.Log("transport", "HTTP", "addr", "9090")
-		errc <- http.ListenAndServe(":9090", handler)
+		logger.Log("transport", "HTTP", "addr", port)
+		errc <- http.ListenAndServe(":"+port, handler)
 	}()
 
 	// 开始运行，等待结束
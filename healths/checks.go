@@ -0,0 +1,69 @@
+package healths
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync/atomic"
+)
+
+// ErrUnhealthy 布尔型检查失败时返回的错误
+var ErrUnhealthy = errors.New("unhealthy")
+
+// FuncCheck 将返回bool的检查转换为Check，如services.Service.HealthCheck
+func FuncCheck(fn func() bool) Check {
+	return func(ctx context.Context) error {
+		if !fn() {
+			return ErrUnhealthy
+		}
+		return nil
+	}
+}
+
+// DialCheck 检查TCP地址是否可以连接，rawurl可以是host:port或URL
+func DialCheck(rawurl string) Check {
+	addr := rawurl
+	if u, err := url.Parse(rawurl); err == nil && u.Host != "" {
+		addr = u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			addr = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// Gate 可以手动关闭的检查项，如停机时先将实例标记为未就绪
+type Gate struct {
+	closed int32
+	reason string
+}
+
+// NewGate 创建打开状态的Gate，关闭后检查返回reason
+func NewGate(reason string) *Gate {
+	return &Gate{reason: reason}
+}
+
+// Close 关闭Gate，之后的检查均失败
+func (g *Gate) Close() {
+	atomic.StoreInt32(&g.closed, 1)
+}
+
+// Check 作为检查项注册到Registry
+func (g *Gate) Check(ctx context.Context) error {
+	if atomic.LoadInt32(&g.closed) == 1 {
+		return errors.New(g.reason)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package healths
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFuncCheck(t *testing.T) {
+	if err := FuncCheck(func() bool { return true })(context.Background()); err != nil {
+		t.Errorf("healthy: %v", err)
+	}
+	if err := FuncCheck(func() bool { return false })(context.Background()); err != ErrUnhealthy {
+		t.Errorf("unhealthy: error = %v, want ErrUnhealthy", err)
+	}
+}
+
+func TestDialCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, target := range []string{addr, "http://" + addr + "/api/v2/spans"} {
+		if err := DialCheck(target)(ctx); err != nil {
+			t.Errorf("DialCheck(%q): %v", target, err)
+		}
+	}
+	ln.Close()
+	if err := DialCheck(addr)(ctx); err == nil {
+		t.Error("DialCheck succeeded after the listener was closed")
+	}
+}
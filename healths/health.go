@@ -0,0 +1,142 @@
+package healths
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind 检查类型，存活检查失败表示进程需要重启，就绪检查失败表示暂时不应接收流量
+type Kind int
+
+const (
+	Liveness Kind = iota
+	Readiness
+)
+
+// Status 检查结果状态
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// DefaultTimeout 单项检查的默认超时时间
+const DefaultTimeout = time.Second
+
+// Check 单项检查，返回nil表示正常
+type Check func(ctx context.Context) error
+
+// Result 单项检查的结果
+type Result struct {
+	Name      string  `json:"name"`
+	Status    Status  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report 检查报告，任意一项检查失败时Status为down
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Up 所有检查是否通过
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type namedCheck struct {
+	name  string
+	kind  Kind
+	check Check
+}
+
+// Registry 检查注册表，各组件注册自己的检查项
+type Registry struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// NewRegistry 创建检查注册表，timeout为单项检查的超时时间，为0时使用DefaultTimeout
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Registry{timeout: timeout}
+}
+
+// Register 注册检查项，同名检查项会被替换
+func (r *Registry) Register(name string, kind Kind, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.checks {
+		if c.name == name {
+			r.checks[i] = namedCheck{name, kind, check}
+			return
+		}
+	}
+	r.checks = append(r.checks, namedCheck{name, kind, check})
+}
+
+// Run 并发执行检查，就绪检查同时包含存活检查项，结果按名称排序
+func (r *Registry) Run(ctx context.Context, kind Kind) Report {
+	r.mu.RLock()
+	var checks []namedCheck
+	for _, c := range r.checks {
+		if c.kind <= kind {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	report := Report{Status: StatusUp, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// run 执行单项检查，超时视为失败
+func (r *Registry) run(ctx context.Context, c namedCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- c.check(ctx) }()
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{
+		Name:      c.name,
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,78 @@
+package healths
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryRun(t *testing.T) {
+	r := NewRegistry(20 * time.Millisecond)
+	r.Register("b-live", Liveness, func(ctx context.Context) error { return nil })
+	r.Register("a-ready", Readiness, func(ctx context.Context) error { return nil })
+
+	report := r.Run(context.Background(), Liveness)
+	if !report.Up() || len(report.Checks) != 1 || report.Checks[0].Name != "b-live" {
+		t.Errorf("liveness report = %+v, want only the liveness check", report)
+	}
+	report = r.Run(context.Background(), Readiness)
+	if !report.Up() || len(report.Checks) != 2 || report.Checks[0].Name != "a-ready" || report.Checks[1].Name != "b-live" {
+		t.Errorf("readiness report = %+v, want both checks sorted by name", report)
+	}
+
+	// 同名检查项被替换
+	r.Register("a-ready", Readiness, func(ctx context.Context) error { return errors.New("consul unreachable") })
+	report = r.Run(context.Background(), Readiness)
+	if report.Up() || len(report.Checks) != 2 {
+		t.Fatalf("report = %+v, want down with 2 checks", report)
+	}
+	if res := report.Checks[0]; res.Status != StatusDown || res.Error != "consul unreachable" {
+		t.Errorf("failed check = %+v", res)
+	}
+	if r.Run(context.Background(), Liveness).Up() != true {
+		t.Error("a failing readiness check must not fail liveness")
+	}
+}
+
+func TestRegistryRunTimeout(t *testing.T) {
+	r := NewRegistry(20 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	for _, name := range []string{"slow-1", "slow-2", "slow-3"} {
+		r.Register(name, Liveness, func(ctx context.Context) error {
+			// 忽略ctx的检查也不能拖住报告
+			<-release
+			return nil
+		})
+	}
+	r.Register("fast", Liveness, func(ctx context.Context) error { return nil })
+
+	start := time.Now()
+	report := r.Run(context.Background(), Liveness)
+	// 检查并发执行，总耗时约为一次超时
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Errorf("run took %s, want the checks to time out concurrently", d)
+	}
+	if report.Up() {
+		t.Error("report is up with timed out checks")
+	}
+	for _, res := range report.Checks {
+		want := StatusDown
+		if res.Name == "fast" {
+			want = StatusUp
+		}
+		if res.Status != want {
+			t.Errorf("%s: status %s, want %s", res.Name, res.Status, want)
+		}
+		if want == StatusDown && res.Error != context.DeadlineExceeded.Error() {
+			t.Errorf("%s: error %q, want a deadline error", res.Name, res.Error)
+		}
+	}
+}
+
+func TestNewRegistryDefaultTimeout(t *testing.T) {
+	if r := NewRegistry(0); r.timeout != DefaultTimeout {
+		t.Errorf("timeout = %s, want %s", r.timeout, DefaultTimeout)
+	}
+}
@@ -0,0 +1,137 @@
+package limiters
+
+import (
+	"context"
+	"fmt"
+	"learn/apperrors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+)
+
+// ErrOverloaded 并发数达到上限时返回的错误，HTTP状态码为503
+var ErrOverloaded = apperrors.New(apperrors.Unavailable, "too many concurrent requests")
+
+// Algorithm 根据每个请求的结果调整并发上限
+type Algorithm interface {
+	// Update 返回新的并发上限，inflight为请求开始时的并发数，dropped表示请求失败
+	Update(limit, inflight int, latency time.Duration, dropped bool) int
+}
+
+// AIMD 加性增、乘性减：请求正常且并发数达到上限的一半时上限加1，
+// 延迟超过Threshold或请求失败时上限乘以Backoff，上限保持在[Min, Max]之间
+type AIMD struct {
+	Min       int
+	Max       int
+	Backoff   float64
+	Threshold time.Duration
+}
+
+// Update 实现Algorithm
+func (a AIMD) Update(limit, inflight int, latency time.Duration, dropped bool) int {
+	switch {
+	case dropped || latency > a.Threshold:
+		limit = int(float64(limit) * a.Backoff)
+	case inflight*2 >= limit:
+		limit++
+	}
+	if limit < a.Min {
+		limit = a.Min
+	}
+	if limit > a.Max {
+		limit = a.Max
+	}
+	return limit
+}
+
+// ConcurrencyLimiter 限制同时执行的请求数，超出上限的请求立即返回ErrOverloaded
+// algorithm不为nil时根据请求的延迟和结果自适应地调整上限，当前上限同步到gauge
+type ConcurrencyLimiter struct {
+	algorithm Algorithm
+	gauge     metrics.Gauge
+
+	mu       sync.Mutex
+	limit    int
+	inflight int
+}
+
+// NewConcurrencyLimiter 创建并发限制，limit为初始上限，algorithm为nil时上限固定，gauge可以为nil
+func NewConcurrencyLimiter(limit int, algorithm Algorithm, gauge metrics.Gauge) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{algorithm: algorithm, gauge: gauge, limit: limit}
+	if gauge != nil {
+		gauge.Set(float64(limit))
+	}
+	return l
+}
+
+// Limit 当前的并发上限
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Inflight 正在执行的请求数
+func (l *ConcurrencyLimiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// Check 饱和检查，并发数达到上限、新请求会被拒绝时返回错误，可以作为就绪检查注册到healths.Registry
+func (l *ConcurrencyLimiter) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight >= l.limit {
+		return fmt.Errorf("concurrency limiter saturated, %d of %d requests in flight", l.inflight, l.limit)
+	}
+	return nil
+}
+
+// acquire 占用一个并发名额，返回占用后的并发数
+func (l *ConcurrencyLimiter) acquire() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight >= l.limit {
+		return l.inflight, false
+	}
+	l.inflight++
+	return l.inflight, true
+}
+
+// release 释放名额并根据请求的结果调整上限
+func (l *ConcurrencyLimiter) release(inflight int, latency time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if l.algorithm == nil {
+		return
+	}
+	if limit := l.algorithm.Update(l.limit, inflight, latency, dropped); limit != l.limit {
+		l.limit = limit
+		if l.gauge != nil {
+			l.gauge.Set(float64(limit))
+		}
+	}
+}
+
+// Middleware 创建并发限制中间件，服务端错误视为失败，参数错误、限流等客户端错误不影响上限
+func (l *ConcurrencyLimiter) Middleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			inflight, ok := l.acquire()
+			if !ok {
+				return nil, ErrOverloaded
+			}
+			start := time.Now()
+			defer func() {
+				dropped := err != nil && apperrors.HTTPStatus(apperrors.CodeOf(err)) >= http.StatusInternalServerError
+				l.release(inflight, time.Since(start), dropped)
+			}()
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package limiters
+
+import (
+	"context"
+	"errors"
+	"learn/apperrors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/generic"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l := NewConcurrencyLimiter(2, nil, nil)
+	if err := l.Check(context.Background()); err != nil {
+		t.Errorf("idle limiter check: %v", err)
+	}
+	release, done := make(chan struct{}), make(chan error, 2)
+	blocking := l.Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := blocking(context.Background(), nil)
+			done <- err
+		}()
+	}
+	for l.Inflight() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := blocking(context.Background(), nil); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("third request: error = %v, want ErrOverloaded", err)
+	}
+	if err := l.Check(context.Background()); err == nil {
+		t.Error("saturated limiter passed the readiness check")
+	}
+	if code := apperrors.CodeOf(ErrOverloaded); code != apperrors.Unavailable {
+		t.Errorf("code = %s, want %s", code, apperrors.Unavailable)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("admitted request: %v", err)
+		}
+	}
+	if n := l.Inflight(); n != 0 {
+		t.Errorf("%d in flight after all requests finished", n)
+	}
+	if n := l.Limit(); n != 2 {
+		t.Errorf("fixed limit changed to %d", n)
+	}
+	if err := l.Check(context.Background()); err != nil {
+		t.Errorf("check after all requests finished: %v", err)
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	a := AIMD{Min: 2, Max: 10, Backoff: 0.5, Threshold: 100 * time.Millisecond}
+	tests := []struct {
+		name     string
+		limit    int
+		inflight int
+		latency  time.Duration
+		dropped  bool
+		want     int
+	}{
+		{"busy and fast", 6, 3, time.Millisecond, false, 7},
+		{"idle", 6, 2, time.Millisecond, false, 6},
+		{"at max", 10, 10, time.Millisecond, false, 10},
+		{"slow", 6, 3, time.Second, false, 3},
+		{"dropped", 8, 1, time.Millisecond, true, 4},
+		{"at min", 3, 3, time.Second, false, 2},
+	}
+	for _, tt := range tests {
+		if got := a.Update(tt.limit, tt.inflight, tt.latency, tt.dropped); got != tt.want {
+			t.Errorf("%s: Update(%d, %d) = %d, want %d", tt.name, tt.limit, tt.inflight, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrencyLimiterAdaptive(t *testing.T) {
+	gauge := generic.NewGauge("limit")
+	l := NewConcurrencyLimiter(4, AIMD{Min: 1, Max: 8, Backoff: 0.5, Threshold: time.Second}, gauge)
+	if v := gauge.Value(); v != 4 {
+		t.Errorf("initial gauge = %v, want 4", v)
+	}
+
+	var err error
+	mw := l.Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, err
+	})
+	// 并发数1不到上限4的一半，上限不变
+	mw(context.Background(), nil)
+	if n := l.Limit(); n != 4 {
+		t.Errorf("limit = %d after a request below half the limit, want 4", n)
+	}
+
+	// 客户端错误不影响上限
+	err = apperrors.New(apperrors.InvalidArgument, "bad request")
+	mw(context.Background(), nil)
+	if n := l.Limit(); n != 4 {
+		t.Errorf("limit = %d after a client error, want 4", n)
+	}
+
+	err = apperrors.New(apperrors.Internal, "boom")
+	mw(context.Background(), nil)
+	if n := l.Limit(); n != 2 {
+		t.Errorf("limit = %d after a server error, want 2", n)
+	}
+	if v := gauge.Value(); v != 2 {
+		t.Errorf("gauge = %v, want 2", v)
+	}
+
+	// 上限为2时1个并发达到一半，成功的请求使上限加1
+	err = nil
+	mw(context.Background(), nil)
+	if n := l.Limit(); n != 3 {
+		t.Errorf("limit = %d after a request at half the limit, want 3", n)
+	}
+	if v := gauge.Value(); v != 3 {
+		t.Errorf("gauge = %v, want 3", v)
+	}
+}
@@ -0,0 +1,27 @@
+package limiters
+
+import (
+	"fmt"
+	"learn/apperrors"
+)
+
+// BurstError 请求需要的令牌数超过令牌桶容量，重试也不会成功，返回400且不带Retry-After
+type BurstError struct {
+	Weight int
+	Burst  int
+}
+
+func (e *BurstError) Error() string {
+	return fmt.Sprintf("request needs %d rate limit tokens but the bucket holds at most %d", e.Weight, e.Burst)
+}
+
+func (e *BurstError) ErrorCode() apperrors.Code {
+	return apperrors.InvalidArgument
+}
+
+func (e *BurstError) ErrorDetails() interface{} {
+	return map[string]interface{}{
+		"weight": e.Weight,
+		"burst":  e.Burst,
+	}
+}
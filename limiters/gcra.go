@@ -0,0 +1,207 @@
+package limiters
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"golang.org/x/time/rate"
+)
+
+// Store 多个实例共享的限流状态，按GCRA（通用信元速率算法）原子地扣减令牌
+// 每个key只保存理论到达时间（TAT），过期后自动删除
+type Store interface {
+	// Take 扣减key的n个令牌，令牌不足时不扣减
+	Take(ctx context.Context, key string, q Quota, n int) (Result, error)
+}
+
+// emission 配额对应的令牌补充间隔，至少1微秒
+func emission(q Quota) time.Duration {
+	if q.Rate <= 0 {
+		return time.Duration(math.MaxInt64 / 4)
+	}
+	d := time.Duration(float64(time.Second) / q.Rate).Truncate(time.Microsecond)
+	if d < time.Microsecond {
+		d = time.Microsecond
+	}
+	return d
+}
+
+// gcra 根据当前的TAT计算是否允许扣减n个令牌，返回新的TAT
+// n超过burst时永远无法满足，拒绝且RetryAfter为0
+func gcra(now, tat time.Time, q Quota, n int) (time.Time, Result) {
+	interval := emission(q)
+	if tat.Before(now) {
+		tat = now
+	}
+	if n > q.Burst {
+		return tat, gcraResult(q, tat.Sub(now))
+	}
+	next := tat.Add(interval * time.Duration(n))
+	allowAt := next.Add(-interval * time.Duration(q.Burst))
+	if allowAt.After(now) {
+		res := gcraResult(q, tat.Sub(now))
+		res.RetryAfter = allowAt.Sub(now)
+		return tat, res
+	}
+	res := gcraResult(q, next.Sub(now))
+	res.Allowed = true
+	return next, res
+}
+
+// gcraResult 根据TAT与当前时间的差，即令牌桶补满需要的时间，计算剩余令牌数
+func gcraResult(q Quota, reset time.Duration) Result {
+	interval := emission(q)
+	remaining := q.Burst - int((reset+interval-1)/interval)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Result{Limit: q.Burst, Remaining: remaining, Reset: reset}
+}
+
+// MemoryStore 进程内的Store，用于测试和单实例部署
+type MemoryStore struct {
+	mu    sync.Mutex
+	tats  map[string]time.Time
+	calls int
+	now   func() time.Time
+}
+
+// NewMemoryStore 创建进程内的Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tats: make(map[string]time.Time), now: time.Now}
+}
+
+// Take 实现Store
+func (s *MemoryStore) Take(ctx context.Context, key string, q Quota, n int) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	next, res := gcra(now, s.tats[key], q, n)
+	s.tats[key] = next
+
+	// 定期清理已过期的key
+	if s.calls++; s.calls%1024 == 0 {
+		for k, tat := range s.tats {
+			if !tat.After(now) {
+				delete(s.tats, k)
+			}
+		}
+	}
+	return res, nil
+}
+
+// StoreCooldown Store出错后直接使用进程内限流的时间，期间不再访问Store
+const StoreCooldown = 5 * time.Second
+
+// DistributedLimiter 通过Store在所有实例间共享配额的限流器
+// 客户端标识、等级和配额与KeyedLimiter相同，Store不可用时退回到进程内限流
+type DistributedLimiter struct {
+	local  *KeyedLimiter
+	store  Store
+	logger log.Logger
+	// errLog 限制Store不可用时的日志频率
+	errLog *rate.Limiter
+	// skipUntil Store出错后跳过Store的截止时间（UnixNano），避免每个请求都等待超时
+	skipUntil int64
+	cooldown  time.Duration
+	// down Store当前是否不可用，状态变化时更新up并输出日志
+	down int32
+	up   metrics.Gauge
+}
+
+// NewDistributedLimiter 创建共享配额的限流器，local提供客户端识别、配额和Store不可用时的限流，
+// up记录Store是否可用（1或0），可以为nil
+func NewDistributedLimiter(store Store, local *KeyedLimiter, up metrics.Gauge, logger log.Logger) *DistributedLimiter {
+	if up != nil {
+		up.Set(1)
+	}
+	return &DistributedLimiter{
+		local:    local,
+		store:    store,
+		logger:   log.With(logger, "component", "rate-limiter"),
+		errLog:   rate.NewLimiter(rate.Every(10*time.Second), 1),
+		cooldown: StoreCooldown,
+		up:       up,
+	}
+}
+
+// Take 客户端在route上扣减n个令牌，令牌不足时不扣减，n超过令牌桶容量时RetryAfter为0
+// Store出错后的cooldown内直接使用进程内限流，之后的第一个请求重新尝试Store
+func (l *DistributedLimiter) Take(ctx context.Context, route string, n int) Result {
+	if time.Now().UnixNano() < atomic.LoadInt64(&l.skipUntil) {
+		return l.local.Take(ctx, route, n)
+	}
+	key, quota := l.local.resolve(ctx, route)
+	res, err := l.store.Take(ctx, "ratelimit:"+key, quota, n)
+	if err != nil {
+		atomic.StoreInt64(&l.skipUntil, time.Now().Add(l.cooldown).UnixNano())
+		l.setDown(true)
+		if l.errLog.Allow() {
+			l.logger.Log("store", "unavailable", "fallback", "local", "retry_in", l.cooldown, "err", err)
+		}
+		return l.local.Take(ctx, route, n)
+	}
+	if l.setDown(false) {
+		l.logger.Log("store", "available")
+	}
+	return res
+}
+
+// setDown 更新Store的可用状态，返回状态是否变化
+func (l *DistributedLimiter) setDown(down bool) bool {
+	var old, v int32 = 1, 0
+	if down {
+		old, v = 0, 1
+	}
+	if !atomic.CompareAndSwapInt32(&l.down, old, v) {
+		return false
+	}
+	if l.up != nil {
+		l.up.Set(float64(1 - v))
+	}
+	return true
+}
+
+// Wait 按Store返回的等待时间重试，ctx的截止时间之前无法得到令牌时立即返回LimitError，
+// n超过令牌桶容量时返回BurstError
+func (l *DistributedLimiter) Wait(ctx context.Context, route string, n int) (Result, error) {
+	for {
+		res := l.Take(ctx, route, n)
+		if res.Allowed {
+			return res, nil
+		}
+		if n > res.Limit {
+			return res, denied(res, n)
+		}
+		if d, ok := ctx.Deadline(); ok && time.Until(d) < res.RetryAfter {
+			return res, &LimitError{Result: res}
+		}
+		timer := time.NewTimer(res.RetryAfter)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if ctx.Err() == context.Canceled {
+				return Result{}, ctx.Err()
+			}
+			return res, &LimitError{Result: res}
+		}
+	}
+}
+
+// Middleware 创建route的限流中间件，超限时立即拒绝
+func (l *DistributedLimiter) Middleware(route string) endpoint.Middleware {
+	return middleware(l, route)
+}
+
+// WaitMiddleware 创建route的限流中间件，超限时等待令牌，最长等待maxWait或到请求的截止时间
+func (l *DistributedLimiter) WaitMiddleware(route string, maxWait time.Duration) endpoint.Middleware {
+	return waitMiddleware(l, route, maxWait)
+}
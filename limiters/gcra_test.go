@@ -0,0 +1,213 @@
+package limiters
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	s := NewMemoryStore()
+	s.now = clock.Now
+	return s, clock
+}
+
+func TestMemoryStoreGCRA(t *testing.T) {
+	s, clock := newTestStore()
+	ctx := context.Background()
+	q := Quota{Rate: 10, Burst: 3}
+
+	for i := 1; i <= 3; i++ {
+		res, _ := s.Take(ctx, "k", q, 1)
+		if !res.Allowed || res.Remaining != 3-i || res.Limit != 3 {
+			t.Fatalf("take %d = %+v, want allowed with %d remaining", i, res, 3-i)
+		}
+	}
+	res, _ := s.Take(ctx, "k", q, 1)
+	if res.Allowed || res.RetryAfter != 100*time.Millisecond || res.Reset != 300*time.Millisecond {
+		t.Fatalf("take past burst = %+v, want denied with a 100ms retry and 300ms reset", res)
+	}
+
+	clock.now = clock.now.Add(100 * time.Millisecond)
+	if res, _ = s.Take(ctx, "k", q, 1); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("take after one interval = %+v, want allowed", res)
+	}
+	if res, _ = s.Take(ctx, "other", q, 3); !res.Allowed {
+		t.Errorf("another key = %+v, want its own bucket", res)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if res, _ = s.Take(ctx, "k", q, 2); !res.Allowed || res.Remaining != 1 || res.Reset != 200*time.Millisecond {
+		t.Errorf("weighted take on a full bucket = %+v, want 1 remaining and 200ms reset", res)
+	}
+	if res, _ = s.Take(ctx, "k", q, 2); res.Allowed || res.RetryAfter != 100*time.Millisecond {
+		t.Errorf("weighted take = %+v, want denied with a 100ms retry", res)
+	}
+}
+
+func TestMemoryStoreExceedsBurst(t *testing.T) {
+	s, _ := newTestStore()
+	ctx := context.Background()
+	q := Quota{Rate: 10, Burst: 3}
+
+	res, _ := s.Take(ctx, "k", q, 4)
+	if res.Allowed || res.RetryAfter != 0 || res.Remaining != 3 {
+		t.Errorf("take 4 of 3 = %+v, want denied without a retry time", res)
+	}
+	if res, _ = s.Take(ctx, "k", q, 3); !res.Allowed {
+		t.Errorf("take 3 of 3 after an oversized take = %+v, want allowed", res)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s, clock := newTestStore()
+	ctx := context.Background()
+	q := Quota{Rate: 10, Burst: 3}
+
+	s.Take(ctx, "old", q, 1)
+	clock.now = clock.now.Add(time.Second)
+	for i := 0; i < 1023; i++ {
+		s.Take(ctx, "new", q, 1)
+	}
+	if _, ok := s.tats["old"]; ok {
+		t.Error("expired key was not swept")
+	}
+}
+
+// failingStore 总是失败的Store，记录调用次数
+type failingStore struct {
+	calls int
+}
+
+func (s *failingStore) Take(ctx context.Context, key string, q Quota, n int) (Result, error) {
+	s.calls++
+	return Result{}, errors.New("connection refused")
+}
+
+func newTestDistributed(store Store) *DistributedLimiter {
+	key, _ := ParseKeyFunc("subject")
+	local := NewKeyedLimiter(key, nil, Every(time.Hour, 3), 0)
+	return NewDistributedLimiter(store, local, nil, log.NewNopLogger())
+}
+
+func TestDistributedLimiterSharesQuota(t *testing.T) {
+	store := NewMemoryStore()
+	a, b := newTestDistributed(store), newTestDistributed(store)
+	ctx := subject("alice")
+
+	if n := allowed(a, ctx, "calculate", 2) + allowed(b, ctx, "calculate", 2); n != 3 {
+		t.Errorf("%d allowed across two instances, want the shared burst of 3", n)
+	}
+	if n := allowed(b, subject("bob"), "calculate", 3); n != 3 {
+		t.Errorf("another client: %d allowed, want 3", n)
+	}
+}
+
+func TestDistributedLimiterExceedsBurst(t *testing.T) {
+	l := newTestDistributed(NewMemoryStore())
+	ctx := subject("alice")
+
+	if res := l.Take(ctx, "batch", 4); res.Allowed || res.RetryAfter != 0 {
+		t.Errorf("take 4 of 3 = %+v, want denied without a retry time", res)
+	}
+	var burstErr *BurstError
+	if _, err := l.Wait(ctx, "batch", 4); !errors.As(err, &burstErr) {
+		t.Errorf("wait 4 of 3: error = %v, want BurstError", err)
+	}
+	next := func(ctx context.Context, request interface{}) (interface{}, error) { return nil, nil }
+	if _, err := l.Middleware("batch")(next)(ctx, weighted(4)); !errors.As(err, &burstErr) {
+		t.Errorf("middleware: error = %v, want BurstError", err)
+	}
+}
+
+func TestDistributedLimiterWait(t *testing.T) {
+	key, _ := ParseKeyFunc("subject")
+	local := NewKeyedLimiter(key, nil, Every(20*time.Millisecond, 1), 0)
+	l := NewDistributedLimiter(NewMemoryStore(), local, nil, log.NewNopLogger())
+	ctx := subject("alice")
+
+	l.Take(ctx, "calculate", 1)
+	if res, err := l.Wait(ctx, "calculate", 1); err != nil || !res.Allowed {
+		t.Errorf("wait = %+v, %v, want a token after about 20ms", res, err)
+	}
+	short, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	var limitErr *LimitError
+	if _, err := l.Wait(short, "calculate", 1); !errors.As(err, &limitErr) {
+		t.Errorf("wait past the deadline: error = %v, want LimitError", err)
+	}
+}
+
+func TestDistributedLimiterFallback(t *testing.T) {
+	store := &failingStore{}
+	l := newTestDistributed(store)
+	if n := allowed(l, subject("alice"), "calculate", 5); n != 3 {
+		t.Errorf("%d allowed with the store down, want the local burst of 3", n)
+	}
+}
+
+func TestDistributedLimiterShortCircuit(t *testing.T) {
+	store := &failingStore{}
+	l := newTestDistributed(store)
+	l.cooldown = 20 * time.Millisecond
+	ctx := subject("alice")
+
+	allowed(l, ctx, "calculate", 3)
+	if store.calls != 1 {
+		t.Errorf("store called %d times, want it skipped after the first error", store.calls)
+	}
+	time.Sleep(30 * time.Millisecond)
+	l.Take(ctx, "calculate", 1)
+	if store.calls != 2 {
+		t.Errorf("store called %d times, want a retry after the cooldown", store.calls)
+	}
+}
+
+// flakyStore fail为true时失败，否则使用MemoryStore
+type flakyStore struct {
+	*MemoryStore
+	fail bool
+}
+
+func (s *flakyStore) Take(ctx context.Context, key string, q Quota, n int) (Result, error) {
+	if s.fail {
+		return Result{}, errors.New("connection refused")
+	}
+	return s.MemoryStore.Take(ctx, key, q, n)
+}
+
+func TestDistributedLimiterStoreUp(t *testing.T) {
+	store := &flakyStore{MemoryStore: NewMemoryStore(), fail: true}
+	up := generic.NewGauge("up")
+	key, _ := ParseKeyFunc("subject")
+	l := NewDistributedLimiter(store, NewKeyedLimiter(key, nil, Every(time.Hour, 3), 0), up, log.NewNopLogger())
+	l.cooldown = 0
+	ctx := subject("alice")
+
+	if up.Value() != 1 {
+		t.Errorf("up = %v before any request, want 1", up.Value())
+	}
+	l.Take(ctx, "calculate", 1)
+	if up.Value() != 0 {
+		t.Errorf("up = %v after a store error, want 0", up.Value())
+	}
+	store.fail = false
+	l.Take(ctx, "calculate", 1)
+	if up.Value() != 1 {
+		t.Errorf("up = %v after the store recovered, want 1", up.Value())
+	}
+}
@@ -0,0 +1,129 @@
+package limiters
+
+import (
+	"context"
+	"fmt"
+	"learn/services"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/peer"
+)
+
+type clientContextKey string
+
+const (
+	// RemoteIPContextKey 客户端IP在context中的key
+	RemoteIPContextKey clientContextKey = "RemoteIP"
+	// APIKeyContextKey API key在context中的key
+	APIKeyContextKey clientContextKey = "APIKey"
+)
+
+// APIKeyHeader 携带API key的请求头
+const APIKeyHeader = "X-API-Key"
+
+// ClientToContext HTTP中间件，将客户端IP和API key放入请求的context
+// trustForwarded为true时客户端IP取X-Forwarded-For的最后一项，即前一跳代理（如网关）看到的地址
+func ClientToContext(trustForwarded bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if ip := remoteIP(r, trustForwarded); ip != "" {
+				ctx = context.WithValue(ctx, RemoteIPContextKey, ip)
+			}
+			if key := strings.TrimSpace(r.Header.Get(APIKeyHeader)); key != "" {
+				ctx = context.WithValue(ctx, APIKeyContextKey, key)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func remoteIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if hops := strings.Split(r.Header.Get("X-Forwarded-For"), ","); len(hops) > 0 {
+			if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyFunc 从context中提取限流的客户端标识，无法识别时返回空字符串
+type KeyFunc func(ctx context.Context) string
+
+// KeyBySubject 按JWT中的用户标识限流，需要在身份认证中间件之后使用
+func KeyBySubject(ctx context.Context) string {
+	claims, ok := services.ClaimsFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if claims.UserId != "" {
+		return "sub:" + claims.UserId
+	}
+	if claims.Name != "" {
+		return "sub:" + claims.Name
+	}
+	return ""
+}
+
+// KeyByAPIKey 按X-API-Key请求头限流，服务本身不校验API key，仅在网关已校验时使用
+func KeyByAPIKey(ctx context.Context) string {
+	if key, ok := ctx.Value(APIKeyContextKey).(string); ok && key != "" {
+		return "key:" + key
+	}
+	return ""
+}
+
+// KeyByRemoteIP 按客户端IP限流，gRPC请求取连接的对端地址
+func KeyByRemoteIP(ctx context.Context) string {
+	if ip, ok := ctx.Value(RemoteIPContextKey).(string); ok && ip != "" {
+		return "ip:" + ip
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return ""
+}
+
+// FirstKey 依次尝试各KeyFunc，返回第一个非空的标识
+func FirstKey(funcs ...KeyFunc) KeyFunc {
+	return func(ctx context.Context) string {
+		for _, fn := range funcs {
+			if key := fn(ctx); key != "" {
+				return key
+			}
+		}
+		return ""
+	}
+}
+
+var keyFuncs = map[string]KeyFunc{
+	"subject": KeyBySubject,
+	"api_key": KeyByAPIKey,
+	"ip":      KeyByRemoteIP,
+}
+
+// ParseKeyFunc 解析以逗号分隔的标识来源，可选subject、api_key、ip，如"subject,ip"
+func ParseKeyFunc(s string) (KeyFunc, error) {
+	var funcs []KeyFunc
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		fn, ok := keyFuncs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown rate limit key %q, want subject, api_key or ip", name)
+		}
+		funcs = append(funcs, fn)
+	}
+	return FirstKey(funcs...), nil
+}
@@ -0,0 +1,268 @@
+package limiters
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"learn/services"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"golang.org/x/time/rate"
+)
+
+// DefaultCapacity 默认最多保留的客户端令牌桶数
+const DefaultCapacity = 10000
+
+// anonymous 无法识别客户端时共用的标识
+const anonymous = "anonymous"
+
+// Quota 令牌桶配额，每秒补充Rate个令牌，最多积累Burst个
+type Quota struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Every 每interval补充一个令牌的配额
+func Every(interval time.Duration, burst int) Quota {
+	return Quota{Rate: float64(rate.Every(interval)), Burst: burst}
+}
+
+// Tier 限流等级，拥有任一角色的客户端属于该等级，Roles为空时匹配所有客户端（包括未登录的）
+// Routes为各路由的配额，"*"为未列出路由的配额
+type Tier struct {
+	Name   string           `json:"name"`
+	Roles  []string         `json:"roles"`
+	Routes map[string]Quota `json:"routes"`
+}
+
+// Tiers 按顺序匹配的限流等级，没有匹配的等级或路由时使用默认配额
+type Tiers []Tier
+
+// DefaultTiers 默认等级：power不受默认配额限制，登录、刷新和注销按客户端限制为每5秒一次
+func DefaultTiers() Tiers {
+	auth := Every(5*time.Second, 5)
+	return Tiers{
+		{Name: "power", Roles: []string{"power"}, Routes: map[string]Quota{"*": {Rate: 10, Burst: 20}}},
+		{Name: "default", Routes: map[string]Quota{"login": auth, "refresh": auth, "logout": auth}},
+	}
+}
+
+// LoadTiers 从JSON文件加载限流等级
+func LoadTiers(path string) (Tiers, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tiers Tiers
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return tiers, tiers.validate()
+}
+
+func (t Tiers) validate() error {
+	for i, tier := range t {
+		if tier.Name == "" {
+			return fmt.Errorf("tier %d: name is required", i)
+		}
+		for route, q := range tier.Routes {
+			if q.Rate <= 0 || q.Burst < 1 {
+				return fmt.Errorf("tier %s: route %s: rate must be positive and burst at least 1", tier.Name, route)
+			}
+		}
+	}
+	return nil
+}
+
+// match 返回客户端所属的等级
+func (t Tiers) match(claims *services.ArithmeticCustomClaims) (Tier, bool) {
+	for _, tier := range t {
+		if len(tier.Roles) == 0 {
+			return tier, true
+		}
+		if claims == nil {
+			continue
+		}
+		for _, role := range tier.Roles {
+			if claims.HasRole(role) {
+				return tier, true
+			}
+		}
+	}
+	return Tier{}, false
+}
+
+type bucket struct {
+	key     string
+	quota   Quota
+	limiter *rate.Limiter
+}
+
+// KeyedLimiter 按客户端分别限流，每个客户端、路由一个令牌桶
+// 令牌桶保存在LRU中，超过容量时淘汰最久未使用的客户端
+type KeyedLimiter struct {
+	key      KeyFunc
+	tiers    Tiers
+	capacity int
+
+	mu       sync.Mutex
+	fallback Quota
+	buckets  map[string]*list.Element
+	lru      *list.List
+}
+
+// NewKeyedLimiter 创建按key区分客户端的限流器，fallback为没有匹配等级或路由时的配额，
+// capacity为最多保留的令牌桶数，为0时使用DefaultCapacity
+func NewKeyedLimiter(key KeyFunc, tiers Tiers, fallback Quota, capacity int) *KeyedLimiter {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &KeyedLimiter{
+		key:      key,
+		tiers:    tiers,
+		capacity: capacity,
+		fallback: fallback,
+		buckets:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// SetFallback 修改默认配额，已有的令牌桶在下次使用时更新
+func (l *KeyedLimiter) SetFallback(q Quota) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fallback = q
+}
+
+// Len 当前保留的令牌桶数
+func (l *KeyedLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
+
+// Take 客户端在route上扣减n个令牌，令牌不足时不扣减，n超过令牌桶容量时RetryAfter为0
+func (l *KeyedLimiter) Take(ctx context.Context, route string, n int) Result {
+	b := l.bucket(ctx, route)
+	now := time.Now()
+	r := b.ReserveN(now, n)
+	if !r.OK() {
+		return bucketResult(b, now)
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		res := bucketResult(b, now)
+		res.RetryAfter = delay
+		return res
+	}
+	res := bucketResult(b, now)
+	res.Allowed = true
+	return res
+}
+
+// Wait 使用rate.Limiter.WaitN等待令牌，ctx的截止时间之前无法得到令牌时立即返回LimitError，
+// n超过令牌桶容量时返回BurstError
+func (l *KeyedLimiter) Wait(ctx context.Context, route string, n int) (Result, error) {
+	b := l.bucket(ctx, route)
+	if n > b.Burst() {
+		res := bucketResult(b, time.Now())
+		return res, denied(res, n)
+	}
+	if err := b.WaitN(ctx, n); err != nil {
+		if ctx.Err() == context.Canceled {
+			return Result{}, ctx.Err()
+		}
+		now := time.Now()
+		res := bucketResult(b, now)
+		res.RetryAfter = res.Reset
+		if r := b.ReserveN(now, n); r.OK() {
+			res.RetryAfter = r.DelayFrom(now)
+			r.CancelAt(now)
+		}
+		return res, &LimitError{Result: res}
+	}
+	res := bucketResult(b, time.Now())
+	res.Allowed = true
+	return res, nil
+}
+
+// bucketResult 不消耗令牌地计算令牌桶当前的剩余令牌数和补满时间
+func bucketResult(b *rate.Limiter, now time.Time) Result {
+	burst := b.Burst()
+	res := Result{Limit: burst}
+	r := b.ReserveN(now, burst)
+	if !r.OK() {
+		return res
+	}
+	missing := r.DelayFrom(now)
+	r.CancelAt(now)
+	res.Reset = missing
+	res.Remaining = burst - int(math.Ceil(missing.Seconds()*float64(b.Limit())))
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	return res
+}
+
+// resolve 返回客户端在route上的令牌桶标识和配额
+func (l *KeyedLimiter) resolve(ctx context.Context, route string) (string, Quota) {
+	client := l.key(ctx)
+	if client == "" {
+		client = anonymous
+	}
+	claims, _ := services.ClaimsFromContext(ctx)
+	tier, _ := l.tiers.match(claims)
+
+	quota, ok := tier.Routes[route]
+	if !ok {
+		if quota, ok = tier.Routes["*"]; !ok {
+			l.mu.Lock()
+			quota = l.fallback
+			l.mu.Unlock()
+		}
+	}
+	return tier.Name + "|" + route + "|" + client, quota
+}
+
+// bucket 查找或创建客户端在route上的令牌桶，配额变化时更新
+func (l *KeyedLimiter) bucket(ctx context.Context, route string) *rate.Limiter {
+	key, quota := l.resolve(ctx, route)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		b := e.Value.(*bucket)
+		if b.quota != quota {
+			b.limiter.SetLimit(rate.Limit(quota.Rate))
+			b.limiter.SetBurst(quota.Burst)
+			b.quota = quota
+		}
+		return b.limiter
+	}
+
+	b := &bucket{key: key, quota: quota, limiter: rate.NewLimiter(rate.Limit(quota.Rate), quota.Burst)}
+	l.buckets[key] = l.lru.PushFront(b)
+	for l.lru.Len() > l.capacity {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+	return b.limiter
+}
+
+// Middleware 创建route的限流中间件，超限时立即拒绝
+func (l *KeyedLimiter) Middleware(route string) endpoint.Middleware {
+	return middleware(l, route)
+}
+
+// WaitMiddleware 创建route的限流中间件，超限时等待令牌，最长等待maxWait或到请求的截止时间
+func (l *KeyedLimiter) WaitMiddleware(route string, maxWait time.Duration) endpoint.Middleware {
+	return waitMiddleware(l, route, maxWait)
+}
@@ -0,0 +1,159 @@
+package limiters
+
+import (
+	"context"
+	"errors"
+	"learn/apperrors"
+	"learn/services"
+	"testing"
+	"time"
+)
+
+// subject 已认证用户的context
+func subject(id string, roles ...string) context.Context {
+	claims := &services.ArithmeticCustomClaims{UserId: id, Roles: roles}
+	return context.WithValue(context.Background(), services.JWTClaimsContextKey, claims)
+}
+
+// weighted 按权重扣减令牌的请求
+type weighted int
+
+func (w weighted) Weight() int {
+	return int(w)
+}
+
+func newTestLimiter(capacity int) *KeyedLimiter {
+	key, _ := ParseKeyFunc("subject,ip")
+	return NewKeyedLimiter(key, DefaultTiers(), Every(time.Hour, 3), capacity)
+}
+
+// allowed 连续扣减n次，每次一个令牌，返回成功的次数
+func allowed(l Limiter, ctx context.Context, route string, n int) int {
+	ok := 0
+	for i := 0; i < n; i++ {
+		if l.Take(ctx, route, 1).Allowed {
+			ok++
+		}
+	}
+	return ok
+}
+
+func TestKeyedLimiterPerClient(t *testing.T) {
+	l := newTestLimiter(0)
+	if n := allowed(l, subject("a", "reader"), "calculate", 5); n != 3 {
+		t.Errorf("client a: %d allowed, want the fallback burst of 3", n)
+	}
+	if n := allowed(l, subject("b", "reader"), "calculate", 5); n != 3 {
+		t.Errorf("client b: %d allowed, want its own bucket of 3", n)
+	}
+	if n := allowed(l, subject("a", "reader"), "evaluate", 5); n != 3 {
+		t.Errorf("client a on another route: %d allowed, want 3", n)
+	}
+	if n := allowed(l, subject("p", "power"), "calculate", 30); n != 20 {
+		t.Errorf("power: %d allowed, want the power burst of 20", n)
+	}
+	ip := context.WithValue(context.Background(), RemoteIPContextKey, "192.0.2.1")
+	if n := allowed(l, ip, "login", 10); n != 5 {
+		t.Errorf("login by ip: %d allowed, want 5", n)
+	}
+}
+
+func TestKeyedLimiterResult(t *testing.T) {
+	l := newTestLimiter(0)
+	ctx := subject("a")
+
+	res := l.Take(ctx, "calculate", 2)
+	if !res.Allowed || res.Limit != 3 || res.Remaining != 1 {
+		t.Errorf("first take = %+v, want allowed with 1 of 3 remaining", res)
+	}
+	res = l.Take(ctx, "calculate", 2)
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Hour {
+		t.Errorf("second take = %+v, want denied with a retry within an hour", res)
+	}
+	if res = l.Take(ctx, "calculate", 1); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("denied take consumed tokens: %+v", res)
+	}
+}
+
+func TestKeyedLimiterExceedsBurst(t *testing.T) {
+	l := newTestLimiter(0)
+	ctx := subject("a")
+
+	res := l.Take(ctx, "batch", 4)
+	if res.Allowed || res.RetryAfter != 0 || res.Limit != 3 {
+		t.Errorf("take 4 of 3 = %+v, want denied without a retry time", res)
+	}
+	if res.Remaining != 3 {
+		t.Errorf("take 4 of 3 consumed tokens: %+v", res)
+	}
+
+	_, err := l.Wait(ctx, "batch", 4)
+	var burstErr *BurstError
+	if !errors.As(err, &burstErr) || burstErr.Weight != 4 || burstErr.Burst != 3 {
+		t.Fatalf("wait 4 of 3: error = %v, want BurstError", err)
+	}
+	if code := apperrors.CodeOf(err); code != apperrors.InvalidArgument {
+		t.Errorf("code = %s, want %s", code, apperrors.InvalidArgument)
+	}
+
+	next := func(ctx context.Context, request interface{}) (interface{}, error) { return nil, nil }
+	batch := weighted(4)
+	if _, err := l.Middleware("batch")(next)(ctx, batch); !errors.As(err, &burstErr) {
+		t.Errorf("middleware: error = %v, want BurstError", err)
+	}
+	if _, err := l.Middleware("batch")(next)(ctx, weighted(3)); err != nil {
+		t.Errorf("middleware with weight 3: %v", err)
+	}
+}
+
+func TestKeyedLimiterWait(t *testing.T) {
+	key, _ := ParseKeyFunc("subject")
+	l := NewKeyedLimiter(key, nil, Every(20*time.Millisecond, 1), 0)
+	ctx := subject("a")
+
+	if _, err := l.Wait(ctx, "calculate", 1); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if res, err := l.Wait(ctx, "calculate", 1); err != nil || !res.Allowed {
+		t.Fatalf("wait = %+v, %v", res, err)
+	}
+	if waited := time.Since(start); waited < 10*time.Millisecond {
+		t.Errorf("waited %s, want about 20ms", waited)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	_, err := l.Wait(short, "calculate", 1)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Result.RetryAfter <= 0 {
+		t.Errorf("wait past the deadline: error = %v, want LimitError with a retry time", err)
+	}
+}
+
+func TestKeyedLimiterEviction(t *testing.T) {
+	l := newTestLimiter(2)
+	allowed(l, subject("a"), "calculate", 3)
+	allowed(l, subject("b"), "calculate", 1)
+	allowed(l, subject("c"), "calculate", 1)
+	if n := l.Len(); n != 2 {
+		t.Fatalf("%d buckets, want capacity 2", n)
+	}
+	// a已被淘汰，重新创建的令牌桶是满的
+	if n := allowed(l, subject("a"), "calculate", 3); n != 3 {
+		t.Errorf("evicted client: %d allowed, want a full bucket", n)
+	}
+}
+
+func TestKeyedLimiterSetFallback(t *testing.T) {
+	l := newTestLimiter(0)
+	ctx := subject("a")
+	allowed(l, ctx, "calculate", 3)
+	l.SetFallback(Every(time.Millisecond, 5))
+	// 已有的令牌桶在下次使用时按新配额补充令牌
+	l.Take(ctx, "calculate", 1)
+	time.Sleep(10 * time.Millisecond)
+	if n := allowed(l, ctx, "calculate", 10); n < 5 {
+		t.Errorf("%d allowed after raising the fallback, want at least 5", n)
+	}
+}
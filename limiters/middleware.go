@@ -0,0 +1,57 @@
+package limiters
+
+import (
+	"context"
+	"learn/services"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Limiter 按客户端和路由限流，KeyedLimiter在进程内限流，DistributedLimiter在所有实例间共享配额
+type Limiter interface {
+	// Take 扣减n个令牌，令牌不足时不扣减，Result.Allowed为false
+	// n超过令牌桶容量时Result.RetryAfter为0
+	Take(ctx context.Context, route string, n int) Result
+	// Wait 等待直到扣减n个令牌，ctx的截止时间之前无法得到令牌时返回LimitError，
+	// n超过令牌桶容量时立即返回BurstError
+	Wait(ctx context.Context, route string, n int) (Result, error)
+	Middleware(route string) endpoint.Middleware
+	WaitMiddleware(route string, maxWait time.Duration) endpoint.Middleware
+}
+
+// middleware 超限时立即返回LimitError，按services.Weighted扣减令牌，
+// 权重超过令牌桶容量时返回BurstError
+func middleware(l Limiter, route string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			n := services.RequestWeight(request)
+			res := l.Take(ctx, route, n)
+			recordResult(ctx, res)
+			if !res.Allowed {
+				return nil, denied(res, n)
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// waitMiddleware 超限时等待令牌，maxWait为0时只受请求context的截止时间限制
+func waitMiddleware(l Limiter, route string, maxWait time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			waitCtx := ctx
+			if maxWait > 0 {
+				var cancel context.CancelFunc
+				waitCtx, cancel = context.WithTimeout(ctx, maxWait)
+				defer cancel()
+			}
+			res, err := l.Wait(waitCtx, route, services.RequestWeight(request))
+			recordResult(ctx, res)
+			if err != nil {
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package limiters
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// gcraScript 在Redis中原子地执行GCRA，时间取Redis服务器时间，避免各实例时钟不一致
+// KEYS[1]为key，ARGV为令牌补充间隔（微秒）、burst和扣减的令牌数，
+// 返回{是否允许, 需要等待的微秒数, 补满需要的微秒数}，扣减数超过burst时等待时间为0
+const gcraScript = `
+if redis.replicate_commands then redis.replicate_commands() end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+if n > burst then
+  return {0, 0, tat - now}
+end
+local next = tat + interval * n
+local allow_at = next - interval * burst
+if allow_at > now then
+  return {0, allow_at - now, tat - now}
+end
+redis.call('SET', KEYS[1], string.format('%d', next), 'PX', math.ceil((next - now) / 1000))
+return {1, 0, next - now}
+`
+
+// DefaultRedisTimeout 单次Redis请求（包括建立连接）的默认超时时间
+const DefaultRedisTimeout = 200 * time.Millisecond
+
+// RedisStore 使用Redis保存限流状态的Store，基于redigo的连接池
+type RedisStore struct {
+	pool    *redis.Pool
+	script  *redis.Script
+	timeout time.Duration
+}
+
+// NewRedisStore 根据redis://[:password@]host:port[/db]创建Store，连接在首次使用时建立
+func NewRedisStore(rawurl string) (*RedisStore, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		// url.Error中包含完整的URL，只返回原因
+		return nil, fmt.Errorf("invalid redis url: %v", err.(*url.Error).Err)
+	}
+	if u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not a redis://host:port url", redactURL(u))
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if _, err := strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("%q: invalid database %q", redactURL(u), db)
+		}
+	}
+
+	s := &RedisStore{script: redis.NewScript(1, gcraScript), timeout: DefaultRedisTimeout}
+	s.pool = &redis.Pool{
+		MaxIdle:     16,
+		IdleTimeout: time.Minute,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialURLContext(ctx, rawurl,
+				redis.DialConnectTimeout(s.timeout),
+				redis.DialReadTimeout(s.timeout),
+				redis.DialWriteTimeout(s.timeout),
+			)
+		},
+	}
+	return s, nil
+}
+
+// RedactURL 去掉URL中的用户名和密码，用于输出日志，无法解析时整体隐藏
+func RedactURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "******"
+	}
+	return redactURL(u)
+}
+
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	return redacted.String()
+}
+
+// Take 实现Store，脚本未加载时由redigo改用EVAL执行
+func (s *RedisStore) Take(ctx context.Context, key string, q Quota, n int) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	values, err := redis.Int64s(s.script.DoContext(ctx, conn, key, emission(q).Microseconds(), q.Burst, n))
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("redis: unexpected reply %v", values)
+	}
+	res := gcraResult(q, time.Duration(values[2])*time.Microsecond)
+	res.Allowed = values[0] == 1
+	res.RetryAfter = time.Duration(values[1]) * time.Microsecond
+	return res, nil
+}
+
+// Ping 检查Redis是否可用
+func (s *RedisStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = redis.DoContext(conn, ctx, "PING")
+	return err
+}
+
+// Close 关闭连接池
+func (s *RedisStore) Close() error {
+	return s.pool.Close()
+}
@@ -0,0 +1,110 @@
+package limiters
+
+import (
+	"context"
+	"learn/apperrors"
+	"learn/services"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Result 单次限流的结果，用于生成X-RateLimit响应头
+type Result struct {
+	Allowed bool
+	// Limit 令牌桶容量
+	Limit int
+	// Remaining 扣减后剩余的令牌数
+	Remaining int
+	// Reset 令牌桶补满需要的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时到可以扣减需要等待的时间，为0表示请求的令牌数超过容量，等待也无法满足
+	RetryAfter time.Duration
+}
+
+// LimitError 限流错误，带有重试等待时间，errors.Is可以匹配services.ErrLimitExceed
+type LimitError struct {
+	Result Result
+}
+
+func (e *LimitError) Error() string {
+	return services.ErrLimitExceed.Error()
+}
+
+func (e *LimitError) ErrorCode() apperrors.Code {
+	return apperrors.RateLimited
+}
+
+func (e *LimitError) ErrorDetails() interface{} {
+	return map[string]interface{}{
+		"limit":          e.Result.Limit,
+		"retry_after_ms": e.Result.RetryAfter.Milliseconds(),
+	}
+}
+
+func (e *LimitError) Unwrap() error {
+	return services.ErrLimitExceed
+}
+
+// denied 根据被拒绝的结果返回错误，n超过令牌桶容量时返回BurstError
+func denied(res Result, n int) error {
+	if n > res.Limit {
+		return &BurstError{Weight: n, Burst: res.Limit}
+	}
+	return &LimitError{Result: res}
+}
+
+type resultContextKey struct{}
+
+// recordResult 将限流结果记录到Headers放入context的位置
+func recordResult(ctx context.Context, res Result) {
+	if p, ok := ctx.Value(resultContextKey{}).(*Result); ok {
+		*p = res
+	}
+}
+
+// Headers HTTP中间件，将请求的限流结果写入响应头：X-RateLimit-Limit、X-RateLimit-Remaining、
+// X-RateLimit-Reset（补满需要的秒数），被拒绝且重试可能成功时另有Retry-After（秒）
+func Headers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := new(Result)
+		ctx := context.WithValue(r.Context(), resultContextKey{}, res)
+		next.ServeHTTP(&headerWriter{ResponseWriter: w, result: res}, r.WithContext(ctx))
+	})
+}
+
+// headerWriter 在写出响应头之前补充限流响应头
+type headerWriter struct {
+	http.ResponseWriter
+	result      *Result
+	wroteHeader bool
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if res := w.result; res.Limit > 0 {
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", seconds(res.Reset))
+			if !res.Allowed && res.RetryAfter > 0 {
+				h.Set("Retry-After", seconds(res.RetryAfter))
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// seconds 向上取整的秒数
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
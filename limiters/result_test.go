@@ -0,0 +1,94 @@
+package limiters
+
+import (
+	"context"
+	"errors"
+	"learn/apperrors"
+	"learn/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serve 通过Headers调用handler，handler中记录res
+func serve(res Result, record bool) http.Header {
+	h := Headers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if record {
+			recordResult(r.Context(), res)
+		}
+		w.Write([]byte("ok"))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Header()
+}
+
+func TestHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		res    Result
+		record bool
+		want   map[string]string
+	}{
+		{"allowed", Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 1500 * time.Millisecond}, true,
+			map[string]string{"X-RateLimit-Limit": "3", "X-RateLimit-Remaining": "2", "X-RateLimit-Reset": "2", "Retry-After": ""}},
+		{"denied", Result{Limit: 3, Reset: 3 * time.Second, RetryAfter: 100 * time.Millisecond}, true,
+			map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "3", "Retry-After": "1"}},
+		{"exceeds burst", Result{Limit: 3, Remaining: 3}, true,
+			map[string]string{"X-RateLimit-Limit": "3", "X-RateLimit-Remaining": "3", "Retry-After": ""}},
+		{"not limited", Result{}, false,
+			map[string]string{"X-RateLimit-Limit": "", "Retry-After": ""}},
+	}
+	for _, tt := range tests {
+		h := serve(tt.res, tt.record)
+		for k, v := range tt.want {
+			if got := h.Get(k); got != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, got, v)
+			}
+		}
+	}
+}
+
+func TestLimitError(t *testing.T) {
+	var err error = &LimitError{Result: Result{Limit: 3, RetryAfter: 1500 * time.Millisecond}}
+	if !errors.Is(err, services.ErrLimitExceed) {
+		t.Error("LimitError does not match services.ErrLimitExceed")
+	}
+	if code := apperrors.CodeOf(err); code != apperrors.RateLimited {
+		t.Errorf("code = %s, want %s", code, apperrors.RateLimited)
+	}
+	details := err.(*LimitError).ErrorDetails().(map[string]interface{})
+	if details["limit"] != 3 || details["retry_after_ms"] != int64(1500) {
+		t.Errorf("details = %v", details)
+	}
+}
+
+func TestWaitMiddleware(t *testing.T) {
+	key, _ := ParseKeyFunc("subject")
+	l := NewKeyedLimiter(key, nil, Every(time.Hour, 1), 0)
+	ctx := subject("a")
+	next := func(ctx context.Context, request interface{}) (interface{}, error) { return "ok", nil }
+
+	var res Result
+	recorded := context.WithValue(ctx, resultContextKey{}, &res)
+	if resp, err := l.WaitMiddleware("calculate", time.Second)(next)(recorded, nil); err != nil || resp != "ok" {
+		t.Fatalf("first request = %v, %v", resp, err)
+	}
+	if !res.Allowed || res.Limit != 1 {
+		t.Errorf("recorded result = %+v, want allowed", res)
+	}
+
+	start := time.Now()
+	_, err := l.WaitMiddleware("calculate", 10*time.Millisecond)(next)(recorded, nil)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("error = %v, want LimitError", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("returned after %s, want an immediate error when the wait exceeds maxWait", d)
+	}
+	if res.Allowed || res.RetryAfter <= 0 {
+		t.Errorf("recorded result = %+v, want denied with a retry time", res)
+	}
+}
@@ -2,40 +2,40 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"github.com/openzipkin/zipkin-go"
+	"learn/configs"
 	"learn/endpoints"
+	"learn/healths"
+	"learn/limiters"
+	"learn/pb"
 	"learn/registers"
 	"learn/services"
 	"learn/transports"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	kitendpoint "github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	kitzipkin "github.com/go-kit/kit/tracing/zipkin"
+	"github.com/hashicorp/consul/api"
 	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
 )
 
 func main() {
 
-	var (
-		consulHost  = flag.String("consul_host", "localhost", "consul ip address")
-		consulPort  = flag.String("consul_port", "8500", "consul port")
-		serviceHost = flag.String("service_host", "localhost", "service ip address")
-		servicePort = flag.String("service_port", "9000", "service port")
-		zipkinURL   = flag.String("zipkin.url", "http://192.168.192.146:9411/api/v2/spans", "Zipkin server url")
-	)
-	flag.String("hello", "asan", "姓名")
-	flag.Parse()
+	// 配置依次来自默认值、-config指定的YAML文件、ARITHMETIC_开头的环境变量和命令行参数
+	conf := configs.MustLoad("arithmetic")
 	ctx := context.Background()
-	errChan := make(chan error)
+	errChan := make(chan error, 3)
 	var logger log.Logger
 	{
 		logger = log.NewLogfmtLogger(os.Stderr)
@@ -43,6 +43,9 @@ func main() {
 		logger = log.With(logger, "caller", log.DefaultCaller)
 	}
 
+	// 健康检查注册表，各组件注册自己的存活或就绪检查项
+	health := healths.NewRegistry(healths.DefaultTimeout)
+
 	fieldKeys := []string{"method"}
 	requestCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
 		Namespace: "raysonxin",
@@ -57,16 +60,17 @@ func main() {
 		Name:      "request_latency",
 		Help:      "Total duration of requests in microseconds.",
 	}, fieldKeys)
-	var zipkinTracer *zipkin.Tracer
+	var (
+		zipkinTracer *zipkin.Tracer
+		reporter     = zipkinhttp.NewReporter(conf.Zipkin.URL)
+	)
 	{
 		var (
 			err           error
-			hostPort      = *serviceHost + ":" + *servicePort
+			hostPort      = conf.Service.Host + ":" + strconv.Itoa(conf.Service.Port)
 			serviceName   = "arithmetic-service"
-			useNoopTracer = (*zipkinURL == "")
-			reporter      = zipkinhttp.NewReporter(*zipkinURL)
+			useNoopTracer = (conf.Zipkin.URL == "")
 		)
-		defer reporter.Close()
 		zEP, _ := zipkin.NewEndpoint(serviceName, hostPort)
 		zipkinTracer, err = zipkin.NewTracer(
 			reporter, zipkin.WithLocalEndpoint(zEP), zipkin.WithNoopTracer(useNoopTracer),
@@ -76,23 +80,166 @@ func main() {
 			os.Exit(1)
 		}
 		if !useNoopTracer {
-			logger.Log("tracer", "Zipkin", "type", "Native", "URL", *zipkinURL)
+			logger.Log("tracer", "Zipkin", "type", "Native", "URL", conf.Zipkin.URL)
+			health.Register("zipkin", healths.Readiness, healths.DialCheck(conf.Zipkin.URL))
+		}
+	}
+
+	// 用户凭证存储，未指定文件时使用内存中的演示用户
+	var users services.UserStore
+	if conf.Service.UsersFile != "" {
+		fileUsers, err := services.NewFileUserStore(conf.Service.UsersFile, logger)
+		if err != nil {
+			logger.Log("users_file", conf.Service.UsersFile, "err", err)
+			os.Exit(1)
 		}
+		users = fileUsers
+	} else {
+		memUsers := services.NewMemoryUserStore()
+		memUsers.AddUser("1", "name", "pwd", "power")
+		memUsers.AddUser("2", "reader", "reader", "reader")
+		users = memUsers
+	}
+
+	// token签名配置，未指定时使用随机生成的HS256密钥，重启后已签发的token失效
+	signingCfg := services.DefaultSigningConfig()
+	if conf.Service.JWTConfig != "" {
+		cfg, err := services.LoadSigningConfig(conf.Service.JWTConfig)
+		if err != nil {
+			logger.Log("jwt_config", conf.Service.JWTConfig, "err", err)
+			os.Exit(1)
+		}
+		signingCfg = cfg
+	} else {
+		logger.Log("jwt", "no -jwt_config given, using an ephemeral HS256 key")
+	}
+	tokens, err := services.NewTokenManager(signingCfg)
+	if err != nil {
+		logger.Log("jwt", "load signing keys", "err", err)
+		os.Exit(1)
+	}
+
+	// 动态配置的初始值，token有效期取自签名配置
+	dynamic := configs.DefaultDynamic()
+	dynamic.Token.AccessLifetime, dynamic.Token.RefreshLifetime = tokens.Lifetimes()
+
+	consulConfig := api.DefaultConfig()
+	consulConfig.Address = conf.Consul.Address()
+	consulClient, err := api.NewClient(consulConfig)
+	if err != nil {
+		logger.Log("consul", "create client", "err", err)
+		os.Exit(1)
+	}
+
+	// 已注销token的吊销列表，指定KV前缀时所有实例共享
+	var revoked services.RevocationList = services.NewMemoryRevocationList()
+	if conf.Consul.RevokedPrefix != "" {
+		revoked = services.NewConsulRevocationList(consulClient, conf.Consul.RevokedPrefix)
 	}
 
 	var svc services.Service
-	svc = services.ArithmeticService{}
+	svc = services.NewArithmeticService(users, tokens, revoked)
 	svc = services.Metrics(requestCount, requestLatency)(svc)
 
 	// 日志
 	svc = services.LoggingMiddleware(logger)(svc)
-	endpoint := endpoints.MakeArithmeticEndpoint(svc)
+	health.Register("service", healths.Liveness, healths.FuncCheck(svc.HealthCheck))
+	// 运算注册表，所有运算统一记录日志和监控指标
+	registry := endpoints.DefaultRegistry()
+	registry.Use(endpoints.OperationMetrics(requestCount, requestLatency))
+	registry.Use(endpoints.OperationLogging(logger))
+	calculateEndpoint := endpoints.MakeArithmeticEndpoint(registry)
+	// 按角色授权，未指定策略文件时使用默认策略
+	policy := endpoints.DefaultPolicy()
+	if conf.Service.PolicyFile != "" {
+		if policy, err = endpoints.LoadPolicy(conf.Service.PolicyFile); err != nil {
+			logger.Log("policy_file", conf.Service.PolicyFile, "err", err)
+			os.Exit(1)
+		}
+	}
+	calculateEndpoint = endpoints.NewAuthorizationMiddleware(policy, registry)(calculateEndpoint)
+	endpoint := calculateEndpoint
 	// 限流juju 每秒内容量为3
 	//ratebucket := ratelimit.NewBucket(time.Second*3, 3)
 	//endpoint = services.NewTokenBucketLimitterWithJuju(ratebucket)(endpoint)
-	// 使用内置的 golang.org/x/time/rate 限流中间件
-	ratebucket := rate.NewLimiter(rate.Every(time.Second*4), 3)
-	endpoint = services.NewTokenBucketLimitterWithBuildIn(ratebucket)(endpoint)
+	// 按客户端限流，每个客户端在每个路由上有独立的令牌桶，等级中未配置的路由使用动态配置的默认配额
+	tiers := limiters.DefaultTiers()
+	if conf.Service.LimitsFile != "" {
+		if tiers, err = limiters.LoadTiers(conf.Service.LimitsFile); err != nil {
+			logger.Log("limits_file", conf.Service.LimitsFile, "err", err)
+			os.Exit(1)
+		}
+	}
+	clientKey, err := limiters.ParseKeyFunc(conf.Service.RateLimitKeys)
+	if err != nil {
+		logger.Log("rate_limit_keys", conf.Service.RateLimitKeys, "err", err)
+		os.Exit(1)
+	}
+	keyed := limiters.NewKeyedLimiter(clientKey, tiers, limiters.Every(dynamic.RateLimit.Interval, dynamic.RateLimit.Burst), conf.Service.RateLimitClients)
+	// 指定Redis时所有实例共享配额，Redis不可用时退回到进程内限流，可用状态导出到Prometheus
+	var limiter limiters.Limiter = keyed
+	if conf.Service.RateLimitRedis != "" {
+		store, err := limiters.NewRedisStore(conf.Service.RateLimitRedis)
+		if err != nil {
+			logger.Log("rate_limit_redis", limiters.RedactURL(conf.Service.RateLimitRedis), "err", err)
+			os.Exit(1)
+		}
+		storeUp := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "raysonxin",
+			Subsystem: "arithmetic_service",
+			Name:      "rate_limit_store_up",
+			Help:      "Whether the shared rate limit store answered the last request (1) or failed (0).",
+		}, []string{})
+		limiter = limiters.NewDistributedLimiter(store, keyed, storeUp, logger)
+	}
+	// 超限时立即返回429，指定-service.rate_limit_wait时改为等待令牌
+	limit := limiter.Middleware
+	if conf.Service.RateLimitWait > 0 {
+		limit = func(route string) kitendpoint.Middleware {
+			return limiter.WaitMiddleware(route, conf.Service.RateLimitWait)
+		}
+	}
+	// 按Endpoint限制并发数，超出时返回503，自适应模式下根据延迟调整上限，当前上限导出到Prometheus，
+	// 并发数达到上限时就绪检查失败
+	concurrencyLimit := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "raysonxin",
+		Subsystem: "arithmetic_service",
+		Name:      "concurrency_limit",
+		Help:      "Current concurrency limit per endpoint.",
+	}, []string{"endpoint"})
+	var algorithm limiters.Algorithm
+	if conf.Service.ConcurrencyAdaptive {
+		algorithm = limiters.AIMD{Min: 1, Max: conf.Service.ConcurrencyMax, Backoff: 0.9, Threshold: conf.Service.ConcurrencyLatency}
+	}
+	concurrency := func(name string) kitendpoint.Middleware {
+		if conf.Service.ConcurrencyLimit == 0 {
+			return func(next kitendpoint.Endpoint) kitendpoint.Endpoint { return next }
+		}
+		l := limiters.NewConcurrencyLimiter(conf.Service.ConcurrencyLimit, algorithm, concurrencyLimit.With("endpoint", name))
+		health.Register("concurrency_"+name, healths.Readiness, l.Check)
+		return l.Middleware()
+	}
+	endpoint = concurrency("calculate")(endpoint)
+	endpoint = limit("calculate")(endpoint)
+	// 身份认证，未携带有效token的请求直接拒绝
+	endpoint = services.NewJWTAuthMiddleware(tokens, revoked)(endpoint)
+	endpoint = kitzipkin.TraceEndpoint(zipkinTracer, "calculate-endpoint")(endpoint)
+
+	//批量运算Endpoint，逐条授权，限流按条目数计费
+	batchEndpoint := endpoints.MakeBatchEndpoint(calculateEndpoint, 8)
+	batchEndpoint = concurrency("batch")(batchEndpoint)
+	batchEndpoint = limit("batch")(batchEndpoint)
+	batchEndpoint = services.NewJWTAuthMiddleware(tokens, revoked)(batchEndpoint)
+	batchEndpoint = kitzipkin.TraceEndpoint(zipkinTracer, "batch-endpoint")(batchEndpoint)
+
+	//表达式计算Endpoint，与算术运算共用身份认证和追踪，按路由单独限流
+	evaluateEndpoint := endpoints.MakeEvaluateEndpoint(svc)
+	// 表达式中的每次运算按同样的策略授权
+	evaluateEndpoint = endpoints.NewAuthorizationMiddleware(policy, registry)(evaluateEndpoint)
+	evaluateEndpoint = concurrency("evaluate")(evaluateEndpoint)
+	evaluateEndpoint = limit("evaluate")(evaluateEndpoint)
+	evaluateEndpoint = services.NewJWTAuthMiddleware(tokens, revoked)(evaluateEndpoint)
+	evaluateEndpoint = kitzipkin.TraceEndpoint(zipkinTracer, "evaluate-endpoint")(evaluateEndpoint)
 	// 健康检查
 	//创建健康检查的Endpoint，未增加限流
 	healthEndpoint := endpoints.MakeHealthCheckEndpoint(svc)
@@ -102,43 +249,160 @@ func main() {
 	//把算术运算Endpoint和健康检查Endpoint封装至ArithmeticEndpoints
 	//身份认证Endpoint
 	authEndpoint := endpoints.MakeAuthEndpoint(svc)
-	authEndpoint = services.NewTokenBucketLimitterWithBuildIn(ratebucket)(authEndpoint)
+	authEndpoint = concurrency("login")(authEndpoint)
+	authEndpoint = limit("login")(authEndpoint)
 	authEndpoint = kitzipkin.TraceEndpoint(zipkinTracer, "login-endpoint")(authEndpoint)
 
+	//刷新token和注销Endpoint
+	refreshEndpoint := endpoints.MakeRefreshEndpoint(svc)
+	refreshEndpoint = limit("refresh")(refreshEndpoint)
+	refreshEndpoint = kitzipkin.TraceEndpoint(zipkinTracer, "refresh-endpoint")(refreshEndpoint)
+
+	logoutEndpoint := endpoints.MakeLogoutEndpoint(svc)
+	logoutEndpoint = concurrency("logout")(logoutEndpoint)
+	logoutEndpoint = limit("logout")(logoutEndpoint)
+	logoutEndpoint = kitzipkin.TraceEndpoint(zipkinTracer, "logout-endpoint")(logoutEndpoint)
+
 	endpts := endpoints.ArithmeticEndpoints{
 		ArithmeticEndpoint:  endpoint,
+		OperationsEndpoint:  endpoints.MakeOperationsEndpoint(registry),
+		EvaluateEndpoint:    evaluateEndpoint,
+		BatchEndpoint:       batchEndpoint,
 		HealthCheckEndpoint: healthEndpoint,
+		LivenessEndpoint:    endpoints.MakeHealthReportEndpoint(health, healths.Liveness),
+		ReadinessEndpoint:   endpoints.MakeHealthReportEndpoint(health, healths.Readiness),
 		AuthEndpoint:        authEndpoint,
+		RefreshEndpoint:     refreshEndpoint,
+		LogoutEndpoint:      logoutEndpoint,
+		JWKSEndpoint:        endpoints.MakeJWKSEndpoint(tokens),
 	}
 
 	//创建http.Handler
 	r := transports.MakeHttpHandler(ctx, endpts, logger)
 	// 服务注册
-	registar := registers.Register(*consulHost, *consulPort, *serviceHost, *servicePort, logger)
-	go func() {
-		fmt.Println("Http Server start at port:9000")
-		handler := r
-		errChan <- http.ListenAndServe(":9000", handler)
-	}()
+	registar, registered := registers.Register(conf.Consul.Host, strconv.Itoa(conf.Consul.Port), conf.Service.Host, strconv.Itoa(conf.Service.Port), logger)
+	health.Register("consul", healths.Readiness, registered)
 
-	go func() {
-		fmt.Println("Http Server start at port:" + *servicePort)
-		//启动前执行注册
-		registar.Register()
-		handler := r
-		errChan <- http.ListenAndServe(":"+*servicePort, handler)
-	}()
+	// 限流和token有效期可以通过Consul KV修改，无需重启
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	if conf.Consul.KVKey != "" {
+		watcher := configs.NewWatcher(consulClient, conf.Consul.KVKey, dynamic, logger)
+		watcher.OnChange("rate_limiter", func(d configs.Dynamic) error {
+			keyed.SetFallback(limiters.Every(d.RateLimit.Interval, d.RateLimit.Burst))
+			return nil
+		})
+		watcher.OnChange("token", func(d configs.Dynamic) error {
+			return tokens.SetLifetimes(d.Token.AccessLifetime, d.Token.RefreshLifetime)
+		})
+		go watcher.Run(watchCtx)
+	}
+	// 用户文件修改后定期重新加载，无需重启
+	if fileUsers, ok := users.(*services.FileUserStore); ok {
+		go fileUsers.Run(watchCtx, conf.Service.UsersReload)
+	}
+	// 停机时先关闭，使就绪检查失败
+	serving := healths.NewGate("shutting down")
+	health.Register("shutdown", healths.Readiness, serving.Check)
+
+	httpServers := []*http.Server{{
+		Addr:              ":" + strconv.Itoa(conf.Service.Port),
+		Handler:           limiters.Headers(limiters.ClientToContext(conf.Service.TrustForwardedFor)(r)),
+		ReadHeaderTimeout: conf.Service.ReadHeaderTimeout,
+		ReadTimeout:       conf.Service.ReadTimeout,
+		WriteTimeout:      conf.Service.WriteTimeout,
+		IdleTimeout:       conf.Service.IdleTimeout,
+	}}
+	for _, srv := range httpServers {
+		go func(srv *http.Server) {
+			fmt.Println("Http Server start at port" + srv.Addr)
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				errChan <- err
+			}
+		}(srv)
+	}
+	//启动后执行注册
+	registar.Register()
 
+	grpcServer := grpc.NewServer()
+	pb.RegisterArithmeticServer(grpcServer, transports.MakeGRPCServer(endpts, logger))
 	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-		errChan <- fmt.Errorf("%s", <-c)
+		grpcAddr := ":" + strconv.Itoa(conf.Service.GRPCPort)
+		fmt.Println("gRPC Server start at port" + grpcAddr)
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		errChan <- grpcServer.Serve(lis)
 	}()
 
-	//服务退出，取消注册
-	error := <-errChan
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case sig := <-signals:
+		logger.Log("signal", sig)
+	case err := <-errChan:
+		logger.Log("err", err)
+		exitCode = 1
+	}
+
+	//停机：标记未就绪并取消注册，等待负载均衡摘除实例后关闭服务，再次收到信号时跳过等待
+	serving.Close()
+	stopWatching()
 	registar.Deregister()
-	fmt.Println(error)
-	fmt.Println(<-errChan)
+	logger.Log("shutdown", "draining", "period", conf.Service.DrainPeriod)
+	select {
+	case <-time.After(conf.Service.DrainPeriod):
+	case sig := <-signals:
+		logger.Log("signal", sig, "shutdown", "skip draining")
+	}
 
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.Service.ShutdownTimeout)
+	if err := shutdown(shutdownCtx, httpServers, grpcServer); err != nil {
+		logger.Log("shutdown", "timeout", "err", err)
+		exitCode = 1
+	}
+	cancel()
+
+	// 上报剩余的span
+	if err := reporter.Close(); err != nil {
+		logger.Log("tracer", "close reporter", "err", err)
+	}
+	logger.Log("exit", exitCode)
+	os.Exit(exitCode)
+}
+
+// shutdown 停止接收新请求并等待处理中的请求完成，ctx超时后强制关闭
+func shutdown(ctx context.Context, httpServers []*http.Server, grpcServer *grpc.Server) error {
+	errc := make(chan error, len(httpServers)+1)
+	for _, srv := range httpServers {
+		go func(srv *http.Server) {
+			errc <- srv.Shutdown(ctx)
+		}(srv)
+	}
+	go func() {
+		done := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			errc <- nil
+		case <-ctx.Done():
+			grpcServer.Stop()
+			errc <- ctx.Err()
+		}
+	}()
+
+	var err error
+	for i := 0; i < cap(errc); i++ {
+		if e := <-errc; e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
@@ -0,0 +1,179 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ArithmeticClient is the client API for Arithmetic service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ArithmeticClient interface {
+	// Calculate 运算，需在metadata中携带authorization: Bearer <token>
+	Calculate(ctx context.Context, in *ArithmeticRequest, opts ...grpc.CallOption) (*ArithmeticResponse, error)
+	// HealthCheck 健康检查
+	HealthCheck(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+	// Login 登录获取token
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+}
+
+type arithmeticClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewArithmeticClient(cc grpc.ClientConnInterface) ArithmeticClient {
+	return &arithmeticClient{cc}
+}
+
+func (c *arithmeticClient) Calculate(ctx context.Context, in *ArithmeticRequest, opts ...grpc.CallOption) (*ArithmeticResponse, error) {
+	out := new(ArithmeticResponse)
+	err := c.cc.Invoke(ctx, "/arithmetic.Arithmetic/Calculate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *arithmeticClient) HealthCheck(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, "/arithmetic.Arithmetic/HealthCheck", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *arithmeticClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, "/arithmetic.Arithmetic/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ArithmeticServer is the server API for Arithmetic service.
+// All implementations must embed UnimplementedArithmeticServer
+// for forward compatibility
+type ArithmeticServer interface {
+	// Calculate 运算，需在metadata中携带authorization: Bearer <token>
+	Calculate(context.Context, *ArithmeticRequest) (*ArithmeticResponse, error)
+	// HealthCheck 健康检查
+	HealthCheck(context.Context, *HealthRequest) (*HealthResponse, error)
+	// Login 登录获取token
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	mustEmbedUnimplementedArithmeticServer()
+}
+
+// UnimplementedArithmeticServer must be embedded to have forward compatible implementations.
+type UnimplementedArithmeticServer struct {
+}
+
+func (UnimplementedArithmeticServer) Calculate(context.Context, *ArithmeticRequest) (*ArithmeticResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Calculate not implemented")
+}
+func (UnimplementedArithmeticServer) HealthCheck(context.Context, *HealthRequest) (*HealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
+}
+func (UnimplementedArithmeticServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedArithmeticServer) mustEmbedUnimplementedArithmeticServer() {}
+
+// UnsafeArithmeticServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ArithmeticServer will
+// result in compilation errors.
+type UnsafeArithmeticServer interface {
+	mustEmbedUnimplementedArithmeticServer()
+}
+
+func RegisterArithmeticServer(s grpc.ServiceRegistrar, srv ArithmeticServer) {
+	s.RegisterService(&Arithmetic_ServiceDesc, srv)
+}
+
+func _Arithmetic_Calculate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArithmeticRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArithmeticServer).Calculate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/arithmetic.Arithmetic/Calculate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArithmeticServer).Calculate(ctx, req.(*ArithmeticRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Arithmetic_HealthCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArithmeticServer).HealthCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/arithmetic.Arithmetic/HealthCheck",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArithmeticServer).HealthCheck(ctx, req.(*HealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Arithmetic_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArithmeticServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/arithmetic.Arithmetic/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArithmeticServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Arithmetic_ServiceDesc is the grpc.ServiceDesc for Arithmetic service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Arithmetic_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "arithmetic.Arithmetic",
+	HandlerType: (*ArithmeticServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Calculate",
+			Handler:    _Arithmetic_Calculate_Handler,
+		},
+		{
+			MethodName: "HealthCheck",
+			Handler:    _Arithmetic_HealthCheck_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Arithmetic_Login_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "arithmetic.proto",
+}
@@ -1,6 +1,8 @@
 package registers
 
 import (
+	"context"
+	"learn/healths"
 	"os"
 	"strconv"
 
@@ -13,14 +15,19 @@ import (
 )
 
 // 注册中心的ip  端口 服务的本地ip和端口 日志记录工具
-func Register(consulHost, consulPort, svcHost, svcPort string, logger log.Logger) (register sd.Registrar) {
+// ready为就绪检查项，检查服务是否已注册到Consul
+func Register(consulHost, consulPort, svcHost, svcPort string, logger log.Logger) (register sd.Registrar, ready healths.Check) {
 
 	// 创建Consul客户端连接
-	var client consul.Client
+	var (
+		client       consul.Client
+		consulClient *api.Client
+	)
 	{
 		consulCfg := api.DefaultConfig()
 		consulCfg.Address = consulHost + ":" + consulPort
-		consulClient, err := api.NewClient(consulCfg)
+		var err error
+		consulClient, err = api.NewClient(consulCfg)
 
 		if err != nil {
 			logger.Log("create consul client error:", err)
@@ -32,7 +39,7 @@ func Register(consulHost, consulPort, svcHost, svcPort string, logger log.Logger
 
 	// 设置Consul对服务健康检查的参数
 	check := api.AgentServiceCheck{
-		HTTP:     "http://" + svcHost + ":" + svcPort + "/healht",
+		HTTP:     "http://" + svcHost + ":" + svcPort + "/readyz",
 		Interval: "10s",
 		Timeout:  "1s",
 		Notes:    "Consul check service health status.",
@@ -52,5 +59,10 @@ func Register(consulHost, consulPort, svcHost, svcPort string, logger log.Logger
 
 	// 执行注册
 	register = consul.NewRegistrar(client, &reg, logger)
+
+	ready = func(ctx context.Context) error {
+		_, _, err := consulClient.Agent().Service(reg.ID, (&api.QueryOptions{}).WithContext(ctx))
+		return err
+	}
 	return
 }
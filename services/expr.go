@@ -0,0 +1,314 @@
+package services
+
+import (
+	"fmt"
+	"learn/apperrors"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// ParseError 表达式解析错误，Pos为出错位置（从1开始的字符序号）
+type ParseError struct {
+	Pos int    `json:"position"`
+	Msg string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func (e *ParseError) ErrorCode() apperrors.Code {
+	return apperrors.InvalidArgument
+}
+
+func (e *ParseError) ErrorDetails() interface{} {
+	return e
+}
+
+// MaxExprLength 表达式的最大长度（字符数）
+const MaxExprLength = 4096
+
+// MaxExprDepth 表达式的最大嵌套层数，括号、一元负号和乘方各算一层，避免深层递归耗尽栈空间
+const MaxExprDepth = 256
+
+// Evaluate 计算中缀表达式，支持+ - * / ^、括号、一元负号和变量
+// 优先级从低到高：+ -，* /，一元负号，^（右结合）；check不为nil时在每次二元运算之前调用
+func (s ArithmeticService) Evaluate(expr string, vars map[string]float64, check ExprCheck) (float64, error) {
+	return evaluate(expr, vars, check)
+}
+
+// ExprOperation 表达式中的一次二元运算，Op为运算类型（如OpAdd），A、B为操作数的值
+type ExprOperation struct {
+	Op string
+	A  float64
+	B  float64
+}
+
+// ExprCheck 按计算顺序检查表达式中的每次二元运算，如按运算授权，返回错误时停止计算并返回该错误
+type ExprCheck func(ExprOperation) error
+
+// evaluate 解析并计算表达式
+func evaluate(expr string, vars map[string]float64, check ExprCheck) (float64, error) {
+	input := []rune(expr)
+	if len(input) > MaxExprLength {
+		return 0, &ParseError{Pos: MaxExprLength + 1, Msg: fmt.Sprintf("expression longer than %d characters", MaxExprLength)}
+	}
+	p := &exprParser{input: input, vars: vars, check: check}
+	p.next()
+
+	v, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	if p.tok.kind != tokEOF {
+		return 0, p.errorf("unexpected %s", p.tok)
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, ErrOutOfRange
+	}
+	return v, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOperator
+	tokLParen
+	tokRParen
+	tokInvalid
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t exprToken) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// exprParser 递归下降解析器，解析的同时完成计算
+type exprParser struct {
+	input []rune
+	off   int
+	tok   exprToken
+	vars  map[string]float64
+	depth int
+	// check 二元运算之前的检查，可以为nil
+	check ExprCheck
+}
+
+// operation 执行二元运算之前调用check
+func (p *exprParser) operation(op string, a, b float64) error {
+	if p.check == nil {
+		return nil
+	}
+	return p.check(ExprOperation{Op: op, A: a, B: b})
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) *ParseError {
+	return &ParseError{Pos: p.tok.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next 读取下一个词法单元
+func (p *exprParser) next() {
+	for p.off < len(p.input) && unicode.IsSpace(p.input[p.off]) {
+		p.off++
+	}
+	start := p.off
+	if p.off >= len(p.input) {
+		p.tok = exprToken{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.input[p.off]
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		for p.off < len(p.input) && (unicode.IsDigit(p.input[p.off]) || p.input[p.off] == '.') {
+			p.off++
+		}
+		// 科学计数法，如1e-3
+		if p.off < len(p.input) && (p.input[p.off] == 'e' || p.input[p.off] == 'E') {
+			end := p.off + 1
+			if end < len(p.input) && (p.input[end] == '+' || p.input[end] == '-') {
+				end++
+			}
+			if end < len(p.input) && unicode.IsDigit(p.input[end]) {
+				for end < len(p.input) && unicode.IsDigit(p.input[end]) {
+					end++
+				}
+				p.off = end
+			}
+		}
+		p.tok = exprToken{kind: tokNumber, text: string(p.input[start:p.off]), pos: start}
+	case unicode.IsLetter(c) || c == '_':
+		for p.off < len(p.input) && (unicode.IsLetter(p.input[p.off]) || unicode.IsDigit(p.input[p.off]) || p.input[p.off] == '_') {
+			p.off++
+		}
+		p.tok = exprToken{kind: tokIdent, text: string(p.input[start:p.off]), pos: start}
+	case c == '+' || c == '-' || c == '*' || c == '/' || c == '^':
+		p.off++
+		p.tok = exprToken{kind: tokOperator, text: string(c), pos: start}
+	case c == '(':
+		p.off++
+		p.tok = exprToken{kind: tokLParen, text: "(", pos: start}
+	case c == ')':
+		p.off++
+		p.tok = exprToken{kind: tokRParen, text: ")", pos: start}
+	default:
+		p.off++
+		p.tok = exprToken{kind: tokInvalid, text: string(c), pos: start}
+	}
+}
+
+func (p *exprParser) isOperator(ops ...string) bool {
+	if p.tok.kind != tokOperator {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpr expr = term { ("+" | "-") term }
+func (p *exprParser) parseExpr() (float64, error) {
+	v, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.tok.text
+		p.next()
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if op == "+" {
+			if err := p.operation(OpAdd, v, rhs); err != nil {
+				return 0, err
+			}
+			v += rhs
+		} else {
+			if err := p.operation(OpSubtract, v, rhs); err != nil {
+				return 0, err
+			}
+			v -= rhs
+		}
+	}
+	return v, nil
+}
+
+// parseTerm term = unary { ("*" | "/") unary }
+func (p *exprParser) parseTerm() (float64, error) {
+	v, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for p.isOperator("*", "/") {
+		op := p.tok.text
+		p.next()
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		if op == "*" {
+			if err := p.operation(OpMultiply, v, rhs); err != nil {
+				return 0, err
+			}
+			v *= rhs
+		} else {
+			if err := p.operation(OpDivide, v, rhs); err != nil {
+				return 0, err
+			}
+			if rhs == 0 {
+				return 0, ErrDivideByZero
+			}
+			v /= rhs
+		}
+	}
+	return v, nil
+}
+
+// parseUnary unary = ("-" | "+") unary | power
+func (p *exprParser) parseUnary() (float64, error) {
+	// 所有嵌套都经过parseUnary，在这里限制递归深度
+	if p.depth++; p.depth > MaxExprDepth {
+		return 0, p.errorf("expression nested deeper than %d levels", MaxExprDepth)
+	}
+	defer func() { p.depth-- }()
+
+	if p.isOperator("-", "+") {
+		neg := p.tok.text == "-"
+		p.next()
+		v, err := p.parseUnary()
+		if neg {
+			v = -v
+		}
+		return v, err
+	}
+	return p.parsePower()
+}
+
+// parsePower power = primary [ "^" unary ]，右结合，-2^2 = -4
+func (p *exprParser) parsePower() (float64, error) {
+	v, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if p.isOperator("^") {
+		p.next()
+		exp, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		if err := p.operation(OpPow, v, exp); err != nil {
+			return 0, err
+		}
+		v = math.Pow(v, exp)
+	}
+	return v, nil
+}
+
+// parsePrimary primary = number | variable | "(" expr ")"
+func (p *exprParser) parsePrimary() (float64, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return 0, p.errorf("invalid number %s", tok)
+		}
+		p.next()
+		return v, nil
+	case tokIdent:
+		v, ok := p.vars[tok.text]
+		if !ok {
+			return 0, p.errorf("undefined variable %s", tok)
+		}
+		p.next()
+		return v, nil
+	case tokLParen:
+		p.next()
+		v, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		if p.tok.kind != tokRParen {
+			return 0, p.errorf("expected \")\" to close \"(\" at position %d, got %s", tok.pos+1, p.tok)
+		}
+		p.next()
+		return v, nil
+	}
+	return 0, p.errorf("unexpected %s", tok)
+}
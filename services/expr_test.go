@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"learn/apperrors"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]float64{"x": 3, "rate_1": 0.5}
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 4 / 3", 1},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"2 ^ -1", 0.5},
+		{"--3", 3},
+		{"-x * 2", -6},
+		{"+x - -1", 4},
+		{"x * rate_1", 1.5},
+		{"1e3 + 2.5E-1", 1000.25},
+	}
+	for _, tt := range tests {
+		got, err := ArithmeticService{}.Evaluate(tt.expr, vars, nil)
+		if err != nil {
+			t.Errorf("Evaluate(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		code apperrors.Code
+		pos  int
+	}{
+		{"unknown variable", "1 + y", apperrors.InvalidArgument, 5},
+		{"unclosed paren", "(1 + 2", apperrors.InvalidArgument, 7},
+		{"trailing token", "1 2", apperrors.InvalidArgument, 3},
+		{"invalid character", "1 $ 2", apperrors.InvalidArgument, 3},
+		{"empty", "", apperrors.InvalidArgument, 1},
+		{"divide by zero", "1 / (2 - 2)", apperrors.DivideByZero, 0},
+		{"overflow", "10 ^ 400", apperrors.OutOfRange, 0},
+	}
+	for _, tt := range tests {
+		_, err := ArithmeticService{}.Evaluate(tt.expr, nil, nil)
+		if code := apperrors.CodeOf(err); code != tt.code {
+			t.Errorf("%s: Evaluate(%q) error = %v, want code %s", tt.name, tt.expr, err, tt.code)
+			continue
+		}
+		var perr *ParseError
+		if tt.pos > 0 && (!errors.As(err, &perr) || perr.Pos != tt.pos) {
+			t.Errorf("%s: Evaluate(%q) error = %v, want position %d", tt.name, tt.expr, err, tt.pos)
+		}
+	}
+}
+
+func TestEvaluateLimits(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth)
+	}
+	if _, err := (ArithmeticService{}).Evaluate(nested(MaxExprDepth-1), nil, nil); err != nil {
+		t.Fatalf("nesting below the limit: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"nested parens", nested(MaxExprDepth + 1)},
+		{"unary chain", strings.Repeat("-", MaxExprDepth+1) + "1"},
+		{"power chain", strings.Repeat("1^", MaxExprDepth+1) + "1"},
+		{"deep nesting", strings.Repeat("(", 1000000)},
+		{"too long", strings.Repeat("1+", MaxExprLength/2) + "1"},
+	}
+	for _, tt := range tests {
+		_, err := ArithmeticService{}.Evaluate(tt.expr, nil, nil)
+		var perr *ParseError
+		if !errors.As(err, &perr) || apperrors.CodeOf(err) != apperrors.InvalidArgument {
+			t.Errorf("%s: error = %v, want a ParseError", tt.name, err)
+		}
+	}
+}
+
+func TestEvaluateCheck(t *testing.T) {
+	var ops []ExprOperation
+	record := func(op ExprOperation) error {
+		ops = append(ops, op)
+		return nil
+	}
+	_, err := ArithmeticService{}.Evaluate("(1 + x) * 2 ^ 3 / 0", map[string]float64{"x": 2}, record)
+	if !errors.Is(err, ErrDivideByZero) {
+		t.Fatalf("error = %v, want ErrDivideByZero", err)
+	}
+	want := []ExprOperation{
+		{OpAdd, 1, 2},
+		{OpPow, 2, 3},
+		{OpMultiply, 3, 8},
+		{OpDivide, 24, 0},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("operations = %v, want %v", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("operation %d = %v, want %v", i, ops[i], want[i])
+		}
+	}
+
+	// check返回错误时停止计算
+	denied := errors.New("denied")
+	ops = nil
+	_, err = ArithmeticService{}.Evaluate("2 ^ 3 + 1", nil, func(op ExprOperation) error {
+		ops = append(ops, op)
+		if op.Op == OpPow {
+			return denied
+		}
+		return nil
+	})
+	if err != denied || len(ops) != 1 {
+		t.Errorf("error = %v after %v, want the check error before any other operation", err, ops)
+	}
+}
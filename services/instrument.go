@@ -1,105 +1,131 @@
-package services
-
-import (
-	"context"
-	"errors"
-	"time"
-
-	"github.com/go-kit/kit/endpoint"
-	"github.com/go-kit/kit/metrics"
-	"github.com/juju/ratelimit"
-	"golang.org/x/time/rate"
-)
-
-var ErrLimitExceed = errors.New("Rate limit exceed!")
-
-// 使用juju/ratelimit创建限流中间件
-func NewTokenBucketLimitterWithJuju(bkt *ratelimit.Bucket) endpoint.Middleware {
-	return func(next endpoint.Endpoint) endpoint.Endpoint {
-		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-			if bkt.TakeAvailable(1) == 0 {
-				return nil, ErrLimitExceed
-			}
-			return next(ctx, request)
-		}
-	}
-}
-
-// 使用内置的x/time/rate创建限流中间件
-
-func NewTokenBucketLimitterWithBuildIn(bkt *rate.Limiter) endpoint.Middleware {
-	return func(next endpoint.Endpoint) endpoint.Endpoint {
-		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-			if !bkt.Allow() {
-				return nil, ErrLimitExceed
-			}
-			return next(ctx, request)
-		}
-	}
-}
-
-// metricMiddleware 定义监控中间件，嵌入Service
-// 新增监控指标项：requestCount和requestLatency
-type metricMiddleware struct {
-	Service
-	requestCount   metrics.Counter
-	requestLatency metrics.Histogram
-}
-
-// Metrics 指标采集方法
-func Metrics(requestCount metrics.Counter, requestLatency metrics.Histogram) ServiceMiddleware {
-	return func(next Service) Service {
-		return metricMiddleware{
-			next,
-			requestCount,
-			requestLatency}
-	}
-}
-
-func (mw metricMiddleware) Add(a, b int) (ret int) {
-
-	defer func(beign time.Time) {
-		lvs := []string{"method", "Add"}
-		mw.requestCount.With(lvs...).Add(1)
-		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
-	}(time.Now())
-
-	ret = mw.Service.Add(a, b)
-	return ret
-}
-
-func (mw metricMiddleware) Subtract(a, b int) (ret int) {
-
-	defer func(beign time.Time) {
-		lvs := []string{"method", "Subtract"}
-		mw.requestCount.With(lvs...).Add(1)
-		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
-	}(time.Now())
-
-	ret = mw.Service.Subtract(a, b)
-	return ret
-}
-
-func (mw metricMiddleware) Multiply(a, b int) (ret int) {
-
-	defer func(beign time.Time) {
-		lvs := []string{"method", "Multiply"}
-		mw.requestCount.With(lvs...).Add(1)
-		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
-	}(time.Now())
-
-	ret = mw.Service.Multiply(a, b)
-	return ret
-}
-
-func (mw metricMiddleware) Divide(a, b int) (ret int, err error) {
-
-	defer func(beign time.Time) {
-		lvs := []string{"method", "Divide"}
-		mw.requestCount.With(lvs...).Add(1)
-		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
-	}(time.Now())
-
-	ret, err = mw.Service.Divide(a, b)
-	return
-}
+package services
+
+import (
+	"context"
+	"learn/apperrors"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+	"github.com/juju/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+var ErrLimitExceed = apperrors.New(apperrors.RateLimited, "Rate limit exceed!")
+
+// Weighted 请求实现该接口时，限流中间件按Weight()扣减令牌，如批量请求按条目数计费
+type Weighted interface {
+	Weight() int
+}
+
+// RequestWeight 返回请求需要扣减的令牌数，至少为1
+func RequestWeight(request interface{}) int {
+	if w, ok := request.(Weighted); ok && w.Weight() > 1 {
+		return w.Weight()
+	}
+	return 1
+}
+
+// 使用juju/ratelimit创建限流中间件
+func NewTokenBucketLimitterWithJuju(bkt *ratelimit.Bucket) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			// 令牌不足时不扣减
+			if _, ok := bkt.TakeMaxDuration(int64(RequestWeight(request)), 0); !ok {
+				return nil, ErrLimitExceed
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// 使用内置的x/time/rate创建限流中间件
+
+func NewTokenBucketLimitterWithBuildIn(bkt *rate.Limiter) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			if !bkt.AllowN(time.Now(), RequestWeight(request)) {
+				return nil, ErrLimitExceed
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// metricMiddleware 定义监控中间件，嵌入Service
+// 新增监控指标项：requestCount和requestLatency
+type metricMiddleware struct {
+	Service
+	requestCount   metrics.Counter
+	requestLatency metrics.Histogram
+}
+
+// Metrics 指标采集方法
+func Metrics(requestCount metrics.Counter, requestLatency metrics.Histogram) ServiceMiddleware {
+	return func(next Service) Service {
+		return metricMiddleware{
+			next,
+			requestCount,
+			requestLatency}
+	}
+}
+
+func (mw metricMiddleware) Add(a, b int) (ret int) {
+
+	defer func(beign time.Time) {
+		lvs := []string{"method", "Add"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
+	}(time.Now())
+
+	ret = mw.Service.Add(a, b)
+	return ret
+}
+
+func (mw metricMiddleware) Subtract(a, b int) (ret int) {
+
+	defer func(beign time.Time) {
+		lvs := []string{"method", "Subtract"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
+	}(time.Now())
+
+	ret = mw.Service.Subtract(a, b)
+	return ret
+}
+
+func (mw metricMiddleware) Multiply(a, b int) (ret int) {
+
+	defer func(beign time.Time) {
+		lvs := []string{"method", "Multiply"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
+	}(time.Now())
+
+	ret = mw.Service.Multiply(a, b)
+	return ret
+}
+
+func (mw metricMiddleware) Divide(a, b int) (ret int, err error) {
+
+	defer func(beign time.Time) {
+		lvs := []string{"method", "Divide"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
+	}(time.Now())
+
+	ret, err = mw.Service.Divide(a, b)
+	return
+}
+
+func (mw metricMiddleware) Evaluate(expr string, vars map[string]float64, check ExprCheck) (ret float64, err error) {
+
+	defer func(beign time.Time) {
+		lvs := []string{"method", "Evaluate"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(beign).Seconds())
+	}(time.Now())
+
+	ret, err = mw.Service.Evaluate(expr, vars, check)
+	return
+}
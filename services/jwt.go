@@ -1,45 +1,105 @@
 package services
 
 import (
+	"context"
+	"learn/apperrors"
+
 	"github.com/dgrijalva/jwt-go"
-	"time"
+	"github.com/go-kit/kit/endpoint"
+)
+
+// token类型
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
 )
 
-//secret key
-var secretKey = []byte("abcd1234!@#$")
+type jwtContextKey string
+
+const (
+	// JWTTokenContextKey 原始token在context中的key
+	JWTTokenContextKey jwtContextKey = "JWTToken"
+	// JWTClaimsContextKey 解析后的声明在context中的key
+	JWTClaimsContextKey jwtContextKey = "JWTClaims"
+)
+
+var (
+	ErrTokenContextMissing     = apperrors.New(apperrors.Unauthenticated, "token up for parsing was not passed through the context")
+	ErrTokenMalformed          = apperrors.New(apperrors.Unauthenticated, "token is malformed")
+	ErrTokenExpired            = apperrors.New(apperrors.Unauthenticated, "token is expired")
+	ErrTokenInvalid            = apperrors.New(apperrors.Unauthenticated, "token is invalid")
+	ErrTokenInvalidIssuer      = apperrors.New(apperrors.Unauthenticated, "token issuer is invalid")
+	ErrUnexpectedSigningMethod = apperrors.New(apperrors.Unauthenticated, "unexpected signing method")
+	ErrUnknownKeyID            = apperrors.New(apperrors.Unauthenticated, "token key id is unknown")
+	ErrTokenRevoked            = apperrors.New(apperrors.Unauthenticated, "token has been revoked")
+	ErrTokenType               = apperrors.New(apperrors.Unauthenticated, "token type is invalid")
+)
+
+// IsAuthError 判断是否为身份认证错误，包括token错误和用户名密码错误
+func IsAuthError(err error) bool {
+	return err != nil && apperrors.CodeOf(err) == apperrors.Unauthenticated
+}
 
 // ArithmeticCustomClaims 自定义声明
 type ArithmeticCustomClaims struct {
-	UserId string `json:"userId"`
-	Name   string `json:"name"`
+	UserId    string   `json:"userId"`
+	Name      string   `json:"name"`
+	TokenType string   `json:"tokenType"`
+	Roles     []string `json:"roles,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
 
 	jwt.StandardClaims
 }
 
-// jwtKeyFunc 返回密钥
-func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
-	return secretKey, nil
+// HasRole 判断是否拥有角色
+func (c *ArithmeticCustomClaims) HasRole(role string) bool {
+	return containsString(c.Roles, role)
 }
 
-// Sign 生成token
-func Sign(name, uid string) (string, error) {
-
-	//为了演示方便，设置两分钟后过期
-	expAt := time.Now().Add(time.Duration(2) * time.Minute).Unix()
+// HasScope 判断是否拥有权限范围
+func (c *ArithmeticCustomClaims) HasScope(scope string) bool {
+	return containsString(c.Scopes, scope)
+}
 
-	// 创建声明
-	claims := ArithmeticCustomClaims{
-		UserId: uid,
-		Name:   name,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expAt,
-			Issuer:    "system",
-		},
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
 	}
+	return false
+}
+
+// Token 登录或刷新后签发的token
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // 访问token有效期，单位秒
+}
 
-	//创建token，指定加密算法为HS256
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+// NewJWTAuthMiddleware 创建身份认证中间件
+// 从context中取出访问token并校验，校验通过后将声明放入context
+func NewJWTAuthMiddleware(tokens *TokenManager, revoked RevocationList) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			tokenString, ok := ctx.Value(JWTTokenContextKey).(string)
+			if !ok || tokenString == "" {
+				return nil, ErrTokenContextMissing
+			}
+
+			claims, err := tokens.VerifyToken(tokenString, AccessTokenType, revoked)
+			if err != nil {
+				return nil, err
+			}
+
+			ctx = context.WithValue(ctx, JWTClaimsContextKey, claims)
+			return next(ctx, request)
+		}
+	}
+}
 
-	//生成token
-	return token.SignedString(secretKey)
+// ClaimsFromContext 从context中获取已校验的声明
+func ClaimsFromContext(ctx context.Context) (*ArithmeticCustomClaims, bool) {
+	claims, ok := ctx.Value(JWTClaimsContextKey).(*ArithmeticCustomClaims)
+	return claims, ok
 }
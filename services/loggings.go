@@ -1,78 +1,95 @@
-package services
-
-import (
-	"time"
-
-	"github.com/go-kit/kit/log"
-)
-
-type loggingMiddleware struct {
-	Service
-	logger log.Logger
-}
-
-func LoggingMiddleware(logger log.Logger) ServiceMiddleware {
-	return func(next Service) Service {
-		return loggingMiddleware{next, logger}
-	}
-}
-
-func (mv loggingMiddleware) Add(a, b int) (ret int) {
-	defer func(begin time.Time) {
-		mv.logger.Log(
-			"a", a,
-			"b", b,
-			"result", ret,
-			"took", time.Since(begin),
-		)
-	}(time.Now())
-	ret = mv.Service.Add(a, b)
-	return ret
-}
-func (mw loggingMiddleware) Subtract(a, b int) (ret int) {
-
-	defer func(beign time.Time) {
-		mw.logger.Log(
-			"function", "Subtract",
-			"a", a,
-			"b", b,
-			"result", ret,
-			"took", time.Since(beign),
-		)
-	}(time.Now())
-
-	ret = mw.Service.Subtract(a, b)
-	return ret
-}
-
-func (mw loggingMiddleware) Multiply(a, b int) (ret int) {
-
-	defer func(beign time.Time) {
-		mw.logger.Log(
-			"function", "Multiply",
-			"a", a,
-			"b", b,
-			"result", ret,
-			"took", time.Since(beign),
-		)
-	}(time.Now())
-
-	ret = mw.Service.Multiply(a, b)
-	return ret
-}
-
-func (mw loggingMiddleware) Divide(a, b int) (ret int, err error) {
-
-	defer func(beign time.Time) {
-		mw.logger.Log(
-			"function", "Divide",
-			"a", a,
-			"b", b,
-			"result", ret,
-			"took", time.Since(beign),
-		)
-	}(time.Now())
-
-	ret, err = mw.Service.Divide(a, b)
-	return
-}
+package services
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+type loggingMiddleware struct {
+	Service
+	logger log.Logger
+}
+
+func LoggingMiddleware(logger log.Logger) ServiceMiddleware {
+	return func(next Service) Service {
+		return loggingMiddleware{next, logger}
+	}
+}
+
+func (mv loggingMiddleware) Add(a, b int) (ret int) {
+	defer func(begin time.Time) {
+		mv.logger.Log(
+			"a", a,
+			"b", b,
+			"result", ret,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	ret = mv.Service.Add(a, b)
+	return ret
+}
+func (mw loggingMiddleware) Subtract(a, b int) (ret int) {
+
+	defer func(beign time.Time) {
+		mw.logger.Log(
+			"function", "Subtract",
+			"a", a,
+			"b", b,
+			"result", ret,
+			"took", time.Since(beign),
+		)
+	}(time.Now())
+
+	ret = mw.Service.Subtract(a, b)
+	return ret
+}
+
+func (mw loggingMiddleware) Multiply(a, b int) (ret int) {
+
+	defer func(beign time.Time) {
+		mw.logger.Log(
+			"function", "Multiply",
+			"a", a,
+			"b", b,
+			"result", ret,
+			"took", time.Since(beign),
+		)
+	}(time.Now())
+
+	ret = mw.Service.Multiply(a, b)
+	return ret
+}
+
+func (mw loggingMiddleware) Divide(a, b int) (ret int, err error) {
+
+	defer func(beign time.Time) {
+		mw.logger.Log(
+			"function", "Divide",
+			"a", a,
+			"b", b,
+			"result", ret,
+			"took", time.Since(beign),
+		)
+	}(time.Now())
+
+	ret, err = mw.Service.Divide(a, b)
+	return
+}
+
+func (mw loggingMiddleware) Evaluate(expr string, vars map[string]float64, check ExprCheck) (ret float64, err error) {
+
+	defer func(beign time.Time) {
+		mw.logger.Log(
+			"function", "Evaluate",
+			"expr", expr,
+			"vars", len(vars),
+			"result", ret,
+			"err", err,
+			"took", time.Since(beign),
+		)
+	}(time.Now())
+
+	ret, err = mw.Service.Evaluate(expr, vars, check)
+	return
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"learn/apperrors"
+	"math"
+	"math/big"
+)
+
+// 数值模式
+const (
+	ModeInt     = "int"     // 默认模式，与原有int运算一致
+	ModeInt64   = "int64"   // 64位整数，溢出时返回错误
+	ModeFloat64 = "float64" // 64位浮点数
+	ModeRat     = "rat"     // math/big有理数，结果精确
+)
+
+// 运算类型
+const (
+	OpAdd      = "Add"
+	OpSubtract = "Subtract"
+	OpMultiply = "Multiply"
+	OpDivide   = "Divide"
+)
+
+var (
+	ErrDivideByZero         = apperrors.New(apperrors.DivideByZero, "the divided can not be zero!")
+	ErrOverflow             = apperrors.New(apperrors.OutOfRange, "integer overflow")
+	ErrOutOfRange           = apperrors.New(apperrors.OutOfRange, "result is out of float64 range")
+	ErrUnsupportedOperation = apperrors.New(apperrors.InvalidArgument, "operation is not supported")
+	ErrNilOperand           = apperrors.New(apperrors.InvalidArgument, "operand must not be nil")
+)
+
+// CalculateInt64 64位整数运算，溢出时返回ErrOverflow
+func CalculateInt64(op string, a, b int64) (int64, error) {
+	switch op {
+	case OpAdd:
+		if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+			return 0, ErrOverflow
+		}
+		return a + b, nil
+	case OpSubtract:
+		if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+			return 0, ErrOverflow
+		}
+		return a - b, nil
+	case OpMultiply:
+		if a == 0 || b == 0 {
+			return 0, nil
+		}
+		c := a * b
+		if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return 0, ErrOverflow
+		}
+		return c, nil
+	case OpDivide:
+		if b == 0 {
+			return 0, ErrDivideByZero
+		}
+		if a == math.MinInt64 && b == -1 {
+			return 0, ErrOverflow
+		}
+		return a / b, nil
+	}
+	return 0, ErrUnsupportedOperation
+}
+
+// CalculateFloat64 浮点数运算，结果超出float64范围时返回ErrOutOfRange
+func CalculateFloat64(op string, a, b float64) (float64, error) {
+	var c float64
+	switch op {
+	case OpAdd:
+		c = a + b
+	case OpSubtract:
+		c = a - b
+	case OpMultiply:
+		c = a * b
+	case OpDivide:
+		if b == 0 {
+			return 0, ErrDivideByZero
+		}
+		c = a / b
+	default:
+		return 0, ErrUnsupportedOperation
+	}
+	if math.IsInf(c, 0) || math.IsNaN(c) {
+		return 0, ErrOutOfRange
+	}
+	return c, nil
+}
+
+// CalculateRat 有理数运算，结果精确，操作数为nil时返回ErrNilOperand
+func CalculateRat(op string, a, b *big.Rat) (*big.Rat, error) {
+	if a == nil || b == nil {
+		return nil, ErrNilOperand
+	}
+	c := new(big.Rat)
+	switch op {
+	case OpAdd:
+		return c.Add(a, b), nil
+	case OpSubtract:
+		return c.Sub(a, b), nil
+	case OpMultiply:
+		return c.Mul(a, b), nil
+	case OpDivide:
+		if b.Sign() == 0 {
+			return nil, ErrDivideByZero
+		}
+		return c.Quo(a, b), nil
+	}
+	return nil, ErrUnsupportedOperation
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"fmt"
+	"learn/apperrors"
+	"math"
+)
+
+// 扩展运算类型
+const (
+	OpMod       = "Mod"
+	OpPow       = "Pow"
+	OpSqrt      = "Sqrt"
+	OpGcd       = "Gcd"
+	OpLcm       = "Lcm"
+	OpFactorial = "Factorial"
+)
+
+const (
+	maxInt = int(^uint(0) >> 1)
+	minInt = -maxInt - 1
+)
+
+// DomainError 参数超出运算的定义域
+type DomainError struct {
+	Op     string `json:"operation"`
+	Reason string `json:"reason"`
+}
+
+func (e *DomainError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Op, e.Reason)
+}
+
+func (e *DomainError) ErrorCode() apperrors.Code {
+	return apperrors.InvalidArgument
+}
+
+func (e *DomainError) ErrorDetails() interface{} {
+	return e
+}
+
+// Mod 取余，结果符号与被除数一致
+func Mod(a, b int) (int, error) {
+	if b == 0 {
+		return 0, ErrDivideByZero
+	}
+	if b == -1 {
+		// minInt % -1 在部分平台上会溢出，结果恒为0
+		return 0, nil
+	}
+	return a % b, nil
+}
+
+// Pow 整数幂，指数不能为负数
+func Pow(a, b int) (int, error) {
+	if b < 0 {
+		return 0, &DomainError{Op: OpPow, Reason: "exponent must not be negative"}
+	}
+
+	result, base := 1, a
+	for b > 0 {
+		if b&1 == 1 {
+			r, ok := mulInt(result, base)
+			if !ok {
+				return 0, ErrOverflow
+			}
+			result = r
+		}
+		b >>= 1
+		if b > 0 {
+			next, ok := mulInt(base, base)
+			if !ok {
+				return 0, ErrOverflow
+			}
+			base = next
+		}
+	}
+	return result, nil
+}
+
+// Sqrt 平方根，参数不能为负数
+func Sqrt(a int) (float64, error) {
+	if a < 0 {
+		return 0, &DomainError{Op: OpSqrt, Reason: "argument must not be negative"}
+	}
+	return math.Sqrt(float64(a)), nil
+}
+
+// Gcd 最大公约数，结果非负
+func Gcd(a, b int) (int, error) {
+	if a == minInt || b == minInt {
+		// |minInt|超出int范围，先取余避免取绝对值溢出
+		if a == minInt && b == minInt {
+			return 0, ErrOverflow
+		}
+		if a == minInt {
+			a, b = b, a
+		}
+		if a == 0 {
+			return 0, ErrOverflow
+		}
+		b %= a
+	}
+	a, b = absInt(a), absInt(b)
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a, nil
+}
+
+// Lcm 最小公倍数，结果非负
+func Lcm(a, b int) (int, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	gcd, err := Gcd(a, b)
+	if err != nil {
+		return 0, err
+	}
+	if a == minInt || b == minInt {
+		return 0, ErrOverflow
+	}
+	lcm, ok := mulInt(absInt(a)/gcd, absInt(b))
+	if !ok {
+		return 0, ErrOverflow
+	}
+	return lcm, nil
+}
+
+// Factorial 阶乘，参数不能为负数
+func Factorial(n int) (int, error) {
+	if n < 0 {
+		return 0, &DomainError{Op: OpFactorial, Reason: "argument must not be negative"}
+	}
+	result := 1
+	for i := 2; i <= n; i++ {
+		r, ok := mulInt(result, i)
+		if !ok {
+			return 0, ErrOverflow
+		}
+		result = r
+	}
+	return result, nil
+}
+
+// mulInt 带溢出检测的乘法
+func mulInt(a, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == minInt) || (b == -1 && a == minInt) {
+		return 0, false
+	}
+	return c, true
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
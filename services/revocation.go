@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// RevocationList 已吊销token列表，以token的jti标识
+type RevocationList interface {
+	// Revoke 吊销token，expiresAt之后记录可以清除，返回token在此之前是否已被吊销
+	// 判断和吊销是原子的，同一个id并发吊销时只有一次返回false
+	Revoke(id string, expiresAt time.Time) (bool, error)
+	// IsRevoked 判断token是否已吊销
+	IsRevoked(id string) (bool, error)
+}
+
+// MemoryRevocationList 基于内存的吊销列表
+type MemoryRevocationList struct {
+	mtx     sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevocationList 创建内存吊销列表
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{revoked: make(map[string]time.Time)}
+}
+
+func (l *MemoryRevocationList) Revoke(id string, expiresAt time.Time) (bool, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// 顺便清除已过期的记录，过期token本身已无法通过校验
+	now := time.Now()
+	for k, exp := range l.revoked {
+		if now.After(exp) {
+			delete(l.revoked, k)
+		}
+	}
+	if _, ok := l.revoked[id]; ok {
+		return true, nil
+	}
+	l.revoked[id] = expiresAt
+	return false, nil
+}
+
+func (l *MemoryRevocationList) IsRevoked(id string) (bool, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	_, ok := l.revoked[id]
+	return ok, nil
+}
+
+// ConsulRevocationList 基于Consul KV的吊销列表，多个服务实例共享
+// 每个吊销的token对应prefix下的一个key，值为过期时间的unix时间戳
+type ConsulRevocationList struct {
+	kv     *api.KV
+	prefix string
+}
+
+// NewConsulRevocationList 创建Consul吊销列表，prefix如"arithmetic/revoked/"
+func NewConsulRevocationList(client *api.Client, prefix string) *ConsulRevocationList {
+	return &ConsulRevocationList{kv: client.KV(), prefix: prefix}
+}
+
+// Revoke 使用ModifyIndex为0的CAS写入，key已存在时写入失败，即已被其他实例吊销
+func (l *ConsulRevocationList) Revoke(id string, expiresAt time.Time) (bool, error) {
+	ok, _, err := l.kv.CAS(&api.KVPair{
+		Key:   l.prefix + id,
+		Value: []byte(strconv.FormatInt(expiresAt.Unix(), 10)),
+	}, nil)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+func (l *ConsulRevocationList) IsRevoked(id string) (bool, error) {
+	pair, _, err := l.kv.Get(l.prefix+id, nil)
+	if err != nil {
+		return false, err
+	}
+	if pair == nil {
+		return false, nil
+	}
+
+	// Consul KV没有过期机制，读取到过期记录时删除
+	if exp, err := strconv.ParseInt(string(pair.Value), 10, 64); err == nil && time.Now().Unix() > exp {
+		l.kv.Delete(pair.Key, nil)
+	}
+	return true, nil
+}
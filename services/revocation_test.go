@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryRevocationListRevokeOnce(t *testing.T) {
+	l := NewMemoryRevocationList()
+	exp := time.Now().Add(time.Hour)
+
+	var first int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			revoked, err := l.Revoke("jti", exp)
+			if err != nil {
+				t.Error(err)
+			}
+			if !revoked {
+				atomic.AddInt32(&first, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if first != 1 {
+		t.Errorf("%d revokes saw the id unrevoked, want exactly 1", first)
+	}
+	if ok, _ := l.IsRevoked("jti"); !ok {
+		t.Error("id not revoked")
+	}
+}
+
+func TestRefreshOnce(t *testing.T) {
+	users := NewMemoryUserStore()
+	if err := users.AddUser("1", "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := NewTokenManager(DefaultSigningConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewArithmeticService(users, tokens, NewMemoryRevocationList())
+	token, err := svc.Login("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ok int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Refresh(token.RefreshToken)
+			switch err {
+			case nil:
+				atomic.AddInt32(&ok, 1)
+			case ErrTokenRevoked:
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != 1 {
+		t.Errorf("%d concurrent refreshes succeeded, want exactly 1", ok)
+	}
+}
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"errors"
 	"time"
 )
 
@@ -9,12 +8,24 @@ type Service interface {
 	Add(a, b int) int
 	Subtract(a, b int) int
 	Multiply(a, b int) int
-	Login(name, pwd string) (string, error)
+	Login(name, pwd string) (Token, error)
+	Refresh(refreshToken string) (Token, error)
+	Logout(token string) error
 	Divide(a, b int) (int, error)
+	Evaluate(expr string, vars map[string]float64, check ExprCheck) (float64, error)
 	HealthCheck() bool
 }
 
 type ArithmeticService struct {
+	users   UserStore
+	tokens  *TokenManager
+	revoked RevocationList
+}
+
+// NewArithmeticService 创建服务，users用于登录时校验用户凭证，
+// tokens负责签发token，revoked记录已注销的token
+func NewArithmeticService(users UserStore, tokens *TokenManager, revoked RevocationList) ArithmeticService {
+	return ArithmeticService{users: users, tokens: tokens, revoked: revoked}
 }
 
 func (s ArithmeticService) Add(a, b int) int {
@@ -22,7 +33,7 @@ func (s ArithmeticService) Add(a, b int) int {
 }
 
 func (s ArithmeticService) Subtract(a, b int) int {
-	return a + b
+	return a - b
 }
 func (s ArithmeticService) Multiply(a, b int) int {
 	return a * b
@@ -30,7 +41,7 @@ func (s ArithmeticService) Multiply(a, b int) int {
 
 func (s ArithmeticService) Divide(a, b int) (int, error) {
 	if b == 0 {
-		return 0, errors.New("the divided can not be zero!")
+		return 0, ErrDivideByZero
 	}
 	return a / b, nil
 }
@@ -68,11 +79,53 @@ func (mw metricMiddleware) HealthCheck() (result bool) {
 	return
 }
 
-func (s ArithmeticService) Login(name, pwd string) (string, error) {
-	if name == "name" && pwd == "pwd" {
-		token, err := Sign(name, pwd)
-		return token, err
+func (s ArithmeticService) Login(name, pwd string) (Token, error) {
+	user, err := Authenticate(s.users, name, pwd)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return s.tokens.SignToken(user)
+}
+
+// Refresh 使用刷新token换取新的token，旧的刷新token随即吊销
+func (s ArithmeticService) Refresh(refreshToken string) (Token, error) {
+	claims, err := s.tokens.VerifyToken(refreshToken, RefreshTokenType, s.revoked)
+	if err != nil {
+		return Token{}, err
+	}
+	// 重新查询用户，角色变更在刷新后生效
+	user, err := s.users.FindByName(claims.Name)
+	if err == ErrUserNotFound {
+		return Token{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	// 同一个刷新token并发刷新时只有一次成功
+	revoked, err := s.revoked.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		return Token{}, err
+	}
+	if revoked {
+		return Token{}, ErrTokenRevoked
+	}
+
+	return s.tokens.SignToken(user)
+}
+
+// Logout 吊销token，访问token和刷新token均可
+func (s ArithmeticService) Logout(token string) error {
+	claims, err := s.tokens.ParseToken(token)
+	if err == ErrTokenExpired {
+		// 已过期的token无需吊销
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return "", errors.New("Your name or password dismatch")
+	// 重复注销不是错误
+	_, err = s.revoked.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0))
+	return err
 }
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pborman/uuid"
+)
+
+// Duration 支持"2m"、"168h"格式的时长，用于JSON配置
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// KeyConfig 密钥配置
+// HS256的密钥文件为原始密钥，RS256/ES256为PEM格式的私钥或公钥
+type KeyConfig struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	KeyFile   string `json:"key_file"`
+}
+
+// SigningConfig token签名配置
+type SigningConfig struct {
+	// 当前用于签名的密钥
+	KeyConfig
+	// 仅用于验证的密钥，密钥轮换时保留旧的公钥
+	VerifyKeys []KeyConfig `json:"verify_keys"`
+
+	AccessTokenLifetime  Duration `json:"access_token_lifetime"`
+	RefreshTokenLifetime Duration `json:"refresh_token_lifetime"`
+	Issuer               string   `json:"issuer"`
+}
+
+// DefaultSigningConfig 默认配置，未指定密钥文件时启动时随机生成HS256密钥
+func DefaultSigningConfig() SigningConfig {
+	return SigningConfig{
+		KeyConfig: KeyConfig{
+			Algorithm: jwt.SigningMethodHS256.Alg(),
+		},
+		//为了演示方便，访问token两分钟后过期
+		AccessTokenLifetime:  Duration(2 * time.Minute),
+		RefreshTokenLifetime: Duration(7 * 24 * time.Hour),
+		Issuer:               "system",
+	}
+}
+
+// LoadSigningConfig 从JSON文件加载签名配置，未设置的项使用默认值
+func LoadSigningConfig(path string) (SigningConfig, error) {
+	cfg := DefaultSigningConfig()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// signingKey 签名或验证使用的密钥
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	// 签名使用私钥，HS256为原始密钥
+	private interface{}
+	// 验证使用公钥，HS256为原始密钥
+	public interface{}
+}
+
+// TokenManager 负责token的签发和校验
+type TokenManager struct {
+	signer     signingKey
+	verifyKeys map[string]signingKey
+
+	// mu 保护token有效期，有效期可以在运行时修改
+	mu              sync.RWMutex
+	accessLifetime  time.Duration
+	refreshLifetime time.Duration
+	issuer          string
+}
+
+// NewTokenManager 根据配置加载密钥
+func NewTokenManager(cfg SigningConfig) (*TokenManager, error) {
+	if cfg.AccessTokenLifetime <= 0 || cfg.RefreshTokenLifetime <= 0 {
+		return nil, errors.New("token lifetime must be positive")
+	}
+	if cfg.Issuer == "" {
+		return nil, errors.New("token issuer is required")
+	}
+
+	signer, err := loadKey(cfg.KeyConfig, true)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &TokenManager{
+		signer:          signer,
+		verifyKeys:      map[string]signingKey{signer.id: signer},
+		accessLifetime:  time.Duration(cfg.AccessTokenLifetime),
+		refreshLifetime: time.Duration(cfg.RefreshTokenLifetime),
+		issuer:          cfg.Issuer,
+	}
+	for _, kc := range cfg.VerifyKeys {
+		key, err := loadKey(kc, false)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m.verifyKeys[key.id]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.id)
+		}
+		m.verifyKeys[key.id] = key
+	}
+	return m, nil
+}
+
+// loadKey 读取密钥文件，private为true时读取签名私钥
+func loadKey(kc KeyConfig, private bool) (signingKey, error) {
+	method := jwt.GetSigningMethod(kc.Algorithm)
+	if method == nil {
+		return signingKey{}, fmt.Errorf("unsupported signing algorithm %q", kc.Algorithm)
+	}
+	key := signingKey{id: kc.KeyID, method: method}
+
+	// 未指定签名密钥文件时生成临时HS256密钥，仅适用于单实例开发环境
+	if kc.KeyFile == "" {
+		if !private || method != jwt.SigningMethodHS256 {
+			return key, fmt.Errorf("key %q: key_file is required for %s", kc.KeyID, kc.Algorithm)
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return key, err
+		}
+		if key.id == "" {
+			key.id = uuid.New()
+		}
+		key.private, key.public = secret, secret
+		return key, nil
+	}
+
+	data, err := ioutil.ReadFile(kc.KeyFile)
+	if err != nil {
+		return key, err
+	}
+	if key.id == "" {
+		return key, fmt.Errorf("%s: key_id is required", kc.KeyFile)
+	}
+
+	switch method {
+	case jwt.SigningMethodHS256:
+		secret := bytes.TrimSpace(data)
+		if len(secret) < 32 {
+			return key, fmt.Errorf("%s: HS256 secret must be at least 32 bytes", kc.KeyFile)
+		}
+		key.private, key.public = secret, secret
+	case jwt.SigningMethodRS256:
+		if private {
+			pk, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return key, fmt.Errorf("%s: %v", kc.KeyFile, err)
+			}
+			key.private, key.public = pk, &pk.PublicKey
+		} else {
+			pub, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return key, fmt.Errorf("%s: %v", kc.KeyFile, err)
+			}
+			key.public = pub
+		}
+	case jwt.SigningMethodES256:
+		var pub *ecdsa.PublicKey
+		if private {
+			pk, err := jwt.ParseECPrivateKeyFromPEM(data)
+			if err != nil {
+				return key, fmt.Errorf("%s: %v", kc.KeyFile, err)
+			}
+			key.private, pub = pk, &pk.PublicKey
+		} else {
+			if pub, err = jwt.ParseECPublicKeyFromPEM(data); err != nil {
+				return key, fmt.Errorf("%s: %v", kc.KeyFile, err)
+			}
+		}
+		if pub.Curve.Params().Name != "P-256" {
+			return key, fmt.Errorf("%s: ES256 requires a P-256 key", kc.KeyFile)
+		}
+		key.public = pub
+	default:
+		return key, fmt.Errorf("unsupported signing algorithm %q", kc.Algorithm)
+	}
+	return key, nil
+}
+
+// keyFunc 根据token头部的kid选择验证密钥
+func (m *TokenManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := m.signer
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = m.verifyKeys[kid]; !ok {
+			return nil, ErrUnknownKeyID
+		}
+	}
+
+	// 防止算法混淆，token的算法必须与密钥一致
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return key.public, nil
+}
+
+// Lifetimes 返回访问token和刷新token的有效期
+func (m *TokenManager) Lifetimes() (access, refresh time.Duration) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.accessLifetime, m.refreshLifetime
+}
+
+// SetLifetimes 修改之后签发的token的有效期，已签发的token不受影响
+func (m *TokenManager) SetLifetimes(access, refresh time.Duration) error {
+	if access <= 0 || refresh <= 0 {
+		return errors.New("token lifetime must be positive")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accessLifetime, m.refreshLifetime = access, refresh
+	return nil
+}
+
+// Sign 生成访问token
+func (m *TokenManager) Sign(user User) (string, error) {
+	access, _ := m.Lifetimes()
+	return m.signToken(user, AccessTokenType, access)
+}
+
+// SignRefresh 生成刷新token
+func (m *TokenManager) SignRefresh(user User) (string, error) {
+	_, refresh := m.Lifetimes()
+	return m.signToken(user, RefreshTokenType, refresh)
+}
+
+// SignToken 同时生成访问token和刷新token
+func (m *TokenManager) SignToken(user User) (Token, error) {
+	accessLifetime, refreshLifetime := m.Lifetimes()
+	access, err := m.signToken(user, AccessTokenType, accessLifetime)
+	if err != nil {
+		return Token{}, err
+	}
+	refresh, err := m.signToken(user, RefreshTokenType, refreshLifetime)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(accessLifetime / time.Second),
+	}, nil
+}
+
+func (m *TokenManager) signToken(user User, tokenType string, lifetime time.Duration) (string, error) {
+	now := time.Now()
+
+	// 创建声明，jti用于吊销
+	claims := ArithmeticCustomClaims{
+		UserId:    user.Id,
+		Name:      user.Name,
+		TokenType: tokenType,
+		Roles:     user.Roles,
+		Scopes:    user.Scopes,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(lifetime).Unix(),
+			Issuer:    m.issuer,
+		},
+	}
+
+	//创建token，头部带上kid以便验证方选择公钥
+	token := jwt.NewWithClaims(m.signer.method, claims)
+	token.Header["kid"] = m.signer.id
+
+	//生成token
+	return token.SignedString(m.signer.private)
+}
+
+// ParseToken 校验token的签名、过期时间和签发者，返回自定义声明
+func (m *TokenManager) ParseToken(tokenString string) (*ArithmeticCustomClaims, error) {
+	claims := &ArithmeticCustomClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.keyFunc)
+	if err != nil {
+		if e, ok := err.(*jwt.ValidationError); ok {
+			switch {
+			case e.Errors&jwt.ValidationErrorMalformed != 0:
+				return nil, ErrTokenMalformed
+			case e.Errors&jwt.ValidationErrorExpired != 0:
+				return nil, ErrTokenExpired
+			case e.Inner == ErrUnexpectedSigningMethod, e.Inner == ErrUnknownKeyID:
+				return nil, e.Inner
+			}
+		}
+		return nil, ErrTokenInvalid
+	}
+	if !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+	if !claims.VerifyIssuer(m.issuer, true) {
+		return nil, ErrTokenInvalidIssuer
+	}
+	return claims, nil
+}
+
+// VerifyToken 校验token并检查类型和吊销状态
+func (m *TokenManager) VerifyToken(tokenString, tokenType string, revoked RevocationList) (*ArithmeticCustomClaims, error) {
+	claims, err := m.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrTokenType
+	}
+	isRevoked, err := revoked.IsRevoked(claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if isRevoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// JSONWebKey JWK格式的公钥
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet JWKS文档
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS 返回所有非对称验证密钥的公钥，HS256密钥不公开
+func (m *TokenManager) JWKS() JSONWebKeySet {
+	kids := make([]string, 0, len(m.verifyKeys))
+	for kid := range m.verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, kid := range kids {
+		key := m.verifyKeys[kid]
+		jwk := JSONWebKey{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(padBytes(pub.X.Bytes(), size))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(padBytes(pub.Y.Bytes(), size))
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// padBytes 左侧补零至指定长度
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"learn/apperrors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound       = apperrors.New(apperrors.NotFound, "user not found")
+	ErrUserExists         = apperrors.New(apperrors.InvalidArgument, "user already exists")
+	ErrInvalidCredentials = apperrors.New(apperrors.Unauthenticated, "Your name or password dismatch")
+)
+
+// User 用户信息，密码以bcrypt哈希保存
+type User struct {
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	PasswordHash string   `json:"password_hash"`
+	Roles        []string `json:"roles"`
+	Scopes       []string `json:"scopes"`
+}
+
+// UserStore 用户凭证存储
+type UserStore interface {
+	// FindByName 根据用户名查询用户
+	FindByName(name string) (User, error)
+}
+
+// HashPassword 生成bcrypt密码哈希
+func HashPassword(pwd string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// Authenticate 校验用户名和密码，成功返回用户信息
+func Authenticate(store UserStore, name, pwd string) (User, error) {
+	user, err := store.FindByName(name)
+	if err == ErrUserNotFound {
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(pwd)) != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// MemoryUserStore 基于内存的用户存储
+type MemoryUserStore struct {
+	mtx   sync.RWMutex
+	users map[string]User
+}
+
+// NewMemoryUserStore 创建内存用户存储
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[string]User)}
+}
+
+// AddUser 添加用户，密码以明文传入
+func (s *MemoryUserStore) AddUser(id, name, pwd string, roles ...string) error {
+	hash, err := HashPassword(pwd)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.users[name]; ok {
+		return ErrUserExists
+	}
+	s.users[name] = User{Id: id, Name: name, PasswordHash: hash, Roles: roles}
+	return nil
+}
+
+func (s *MemoryUserStore) FindByName(name string) (User, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	user, ok := s.users[name]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// FileUserStore 基于JSON文件的用户存储
+// 文件内容为User数组，Run定期检查文件，修改后重新加载，加载失败时继续使用上次成功加载的用户
+type FileUserStore struct {
+	path   string
+	logger log.Logger
+
+	mtx     sync.RWMutex
+	modTime time.Time
+	users   map[string]User
+	failed  string // 上次加载失败的错误，相同的错误只记录一次
+}
+
+// NewFileUserStore 从文件加载用户，文件不存在或格式错误时返回错误
+func NewFileUserStore(path string, logger log.Logger) (*FileUserStore, error) {
+	s := &FileUserStore{path: path, logger: log.With(logger, "users_file", path)}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileUserStore) FindByName(name string) (User, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	user, ok := s.users[name]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// Run 每隔interval检查一次文件，直到ctx结束
+func (s *FileUserStore) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.check()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// check 重新加载文件并记录结果，失败时保留原有的用户
+func (s *FileUserStore) check() {
+	err := s.reload()
+	if err == nil {
+		if s.failed != "" {
+			s.failed = ""
+			s.logger.Log("reload", "recovered")
+		}
+		return
+	}
+	if err.Error() != s.failed {
+		s.failed = err.Error()
+		s.logger.Log("reload", "failed, keeping the previous users", "err", err)
+	}
+}
+
+// reload 文件有变化时重新加载
+func (s *FileUserStore) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.RLock()
+	unchanged := s.users != nil && info.ModTime().Equal(s.modTime)
+	s.mtx.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var list []User
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	users := make(map[string]User, len(list))
+	for _, u := range list {
+		if u.Name == "" || u.PasswordHash == "" {
+			return errors.New("user file " + s.path + ": name and password_hash are required")
+		}
+		users[u.Name] = u
+	}
+
+	s.mtx.Lock()
+	s.users = users
+	s.modTime = info.ModTime()
+	s.mtx.Unlock()
+	s.logger.Log("reload", "loaded", "users", len(users))
+	return nil
+}
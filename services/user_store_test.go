@@ -0,0 +1,66 @@
+package services
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// writeUsers 写入用户文件并设置修改时间，避免文件系统的时间精度导致修改未被发现
+func writeUsers(t *testing.T, path, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileUserStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	start := time.Now().Add(-time.Hour)
+	writeUsers(t, path, `[{"id":"1","name":"alice","password_hash":"x"}]`, start)
+
+	var logs bytes.Buffer
+	s, err := NewFileUserStore(path, log.NewLogfmtLogger(&logs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 文件损坏时保留原有用户，相同的错误只记录一次
+	writeUsers(t, path, `[{"id":"1"`, start.Add(time.Minute))
+	s.check()
+	s.check()
+	if _, err := s.FindByName("alice"); err != nil {
+		t.Errorf("alice after a failed reload: %v", err)
+	}
+	if n := strings.Count(logs.String(), "keeping the previous users"); n != 1 {
+		t.Errorf("failure logged %d times, want 1:\n%s", n, logs.String())
+	}
+
+	// 查询不再读取文件
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FindByName("alice"); err != nil {
+		t.Errorf("alice after the file was removed: %v", err)
+	}
+
+	writeUsers(t, path, `[{"id":"2","name":"bob","password_hash":"x"}]`, start.Add(2*time.Minute))
+	s.check()
+	if _, err := s.FindByName("bob"); err != nil {
+		t.Errorf("bob after the fix: %v", err)
+	}
+	if _, err := s.FindByName("alice"); err != ErrUserNotFound {
+		t.Errorf("alice after the fix: error = %v, want ErrUserNotFound", err)
+	}
+	if !strings.Contains(logs.String(), "reload=recovered") {
+		t.Errorf("recovery not logged:\n%s", logs.String())
+	}
+}
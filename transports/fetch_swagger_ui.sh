@@ -0,0 +1,15 @@
+#!/bin/sh
+# 从npm下载swagger-ui-dist中/docs页面使用的文件到swagger-ui目录，由go generate调用
+# 升级时修改swagger-ui/VERSION后重新执行go generate ./transports
+set -e
+cd "$(dirname "$0")"
+version=$(cat swagger-ui/VERSION)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -sSfL "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$version.tgz" | tar -xz -C "$tmp"
+for f in swagger-ui.css swagger-ui-bundle.js LICENSE NOTICE; do
+	if [ -f "$tmp/package/$f" ]; then
+		cp "$tmp/package/$f" swagger-ui/
+	fi
+done
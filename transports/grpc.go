@@ -0,0 +1,156 @@
+package transports
+
+import (
+	"context"
+	"encoding/json"
+	"learn/apperrors"
+	"learn/endpoints"
+	"learn/pb"
+	"learn/services"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer 基于go-kit grpctransport实现pb.ArithmeticServer
+type grpcServer struct {
+	pb.UnimplementedArithmeticServer
+
+	calculate   grpctransport.Handler
+	healthCheck grpctransport.Handler
+	login       grpctransport.Handler
+	logger      log.Logger
+}
+
+// MakeGRPCServer 使用与HTTP相同的Endpoint创建gRPC服务
+func MakeGRPCServer(endpoints endpoints.ArithmeticEndpoints, logger log.Logger) pb.ArithmeticServer {
+	return &grpcServer{
+		calculate: grpctransport.NewServer(
+			endpoints.ArithmeticEndpoint,
+			decodeGRPCArithmeticRequest,
+			encodeGRPCArithmeticResponse,
+			grpctransport.ServerBefore(grpcJWTToContext),
+		),
+		healthCheck: grpctransport.NewServer(
+			endpoints.HealthCheckEndpoint,
+			decodeGRPCHealthRequest,
+			encodeGRPCHealthResponse,
+		),
+		login: grpctransport.NewServer(
+			endpoints.AuthEndpoint,
+			decodeGRPCLoginRequest,
+			encodeGRPCLoginResponse,
+		),
+		logger: logger,
+	}
+}
+
+func (s *grpcServer) Calculate(ctx context.Context, req *pb.ArithmeticRequest) (*pb.ArithmeticResponse, error) {
+	_, resp, err := s.calculate.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return resp.(*pb.ArithmeticResponse), nil
+}
+
+func (s *grpcServer) HealthCheck(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
+	_, resp, err := s.healthCheck.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return resp.(*pb.HealthResponse), nil
+}
+
+func (s *grpcServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	_, resp, err := s.login.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return resp.(*pb.LoginResponse), nil
+}
+
+// grpcJWTToContext 从metadata的authorization中提取Bearer token并放入context
+func grpcJWTToContext(ctx context.Context, md metadata.MD) context.Context {
+	for _, value := range md.Get("authorization") {
+		parts := strings.SplitN(value, " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return context.WithValue(ctx, services.JWTTokenContextKey, strings.TrimSpace(parts[1]))
+		}
+	}
+	return ctx
+}
+
+// error 记录原错误并转换为gRPC状态
+func (s *grpcServer) error(err error) error {
+	s.logger.Log("transport", "grpc", "err", err)
+	return grpcError(err)
+}
+
+// grpcError 将业务错误码转换为gRPC状态码，Internal错误只返回通用信息
+func grpcError(err error) error {
+	code := codes.Internal
+	switch apperrors.CodeOf(err) {
+	case apperrors.InvalidArgument, apperrors.DivideByZero:
+		code = codes.InvalidArgument
+	case apperrors.OutOfRange:
+		code = codes.OutOfRange
+	case apperrors.NotFound:
+		code = codes.NotFound
+	case apperrors.Unauthenticated:
+		code = codes.Unauthenticated
+	case apperrors.PermissionDenied:
+		code = codes.PermissionDenied
+	case apperrors.RateLimited:
+		code = codes.ResourceExhausted
+	case apperrors.Unavailable:
+		code = codes.Unavailable
+	}
+	return status.Error(code, apperrors.From(err).Message)
+}
+
+func decodeGRPCArithmeticRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ArithmeticRequest)
+	return endpoints.ArithmeticRequest{
+		RequestType: req.RequestType,
+		Mode:        req.Mode,
+		A:           json.Number(req.A),
+		B:           json.Number(req.B),
+	}, nil
+}
+
+func encodeGRPCArithmeticResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.ArithmeticResponse)
+	return &pb.ArithmeticResponse{
+		Result:   resp.Result.String(),
+		Mode:     resp.Mode,
+		Rational: resp.Rational,
+	}, nil
+}
+
+func decodeGRPCHealthRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	return endpoints.HealthRequest{}, nil
+}
+
+func encodeGRPCHealthResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.HealthResponse)
+	return &pb.HealthResponse{Status: resp.Status}, nil
+}
+
+func decodeGRPCLoginRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.LoginRequest)
+	return endpoints.AuthRequest{Name: req.Name, Pwd: req.Pwd}, nil
+}
+
+func encodeGRPCLoginResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.AuthResponse)
+	return &pb.LoginResponse{
+		Success:      resp.Success,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    resp.ExpiresIn,
+	}, nil
+}
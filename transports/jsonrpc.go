@@ -0,0 +1,369 @@
+package transports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"learn/apperrors"
+	"learn/endpoints"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/transport/http/jsonrpc"
+)
+
+// JSON-RPC业务错误码，取值位于规范保留给服务端的-32000至-32099之间
+const (
+	rpcApplicationError  = -32000 // 运算失败，如除数为0、溢出
+	rpcUnauthenticated   = -32001 // 身份认证失败
+	rpcPermissionDenied  = -32003 // 授权失败
+	rpcRateLimitExceeded = -32029 // 触发限流
+	rpcUnavailable       = -32053 // 服务过载
+)
+
+// rpcCalculateMethod 算术运算方法名，未注册的方法名也按运算类型交给该方法处理
+const rpcCalculateMethod = "calculate"
+
+// rpcRequest JSON-RPC请求，ID保留原始JSON以区分通知（无id）与id为null的请求
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcResponse JSON-RPC响应
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonrpc.Error  `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcArithmeticResult 运算结果
+type rpcArithmeticResult struct {
+	Result   json.Number `json:"result"`
+	Mode     string      `json:"mode,omitempty"`
+	Rational string      `json:"rational,omitempty"`
+}
+
+var nullID = json.RawMessage("null")
+
+// jsonRPCHandler 在/rpc上提供JSON-RPC 2.0接口，复用与REST相同的Endpoint
+type jsonRPCHandler struct {
+	methods jsonrpc.EndpointCodecMap
+	logger  log.Logger
+}
+
+// MakeJSONRPCHandler 创建JSON-RPC 2.0 Handler
+// 方法名calculate、evaluate、batch、operations、health、login、refresh、logout对应各Endpoint，
+// 其余方法名作为运算类型调用算术运算Endpoint，如{"method":"Add","params":[1,2]}
+func MakeJSONRPCHandler(endpoints endpoints.ArithmeticEndpoints, logger log.Logger) http.Handler {
+	return &jsonRPCHandler{
+		methods: jsonrpc.EndpointCodecMap{
+			rpcCalculateMethod: {
+				Endpoint: endpoints.ArithmeticEndpoint,
+				Decode:   decodeRPCArithmeticRequest,
+				Encode:   encodeRPCArithmeticResponse,
+			},
+			"evaluate": {
+				Endpoint: endpoints.EvaluateEndpoint,
+				Decode:   decodeRPCEvaluateRequest,
+				Encode:   encodeRPCResponse,
+			},
+			"batch": {
+				Endpoint: endpoints.BatchEndpoint,
+				Decode:   decodeRPCBatchRequest,
+				Encode:   encodeRPCResponse,
+			},
+			"operations": {
+				Endpoint: endpoints.OperationsEndpoint,
+				Decode:   decodeRPCOperationsRequest,
+				Encode:   encodeRPCResponse,
+			},
+			"health": {
+				Endpoint: endpoints.HealthCheckEndpoint,
+				Decode:   decodeRPCHealthRequest,
+				Encode:   encodeRPCResponse,
+			},
+			"login": {
+				Endpoint: endpoints.AuthEndpoint,
+				Decode:   decodeRPCLoginRequest,
+				Encode:   encodeRPCResponse,
+			},
+			"refresh": {
+				Endpoint: endpoints.RefreshEndpoint,
+				Decode:   decodeRPCRefreshRequest,
+				Encode:   encodeRPCResponse,
+			},
+			"logout": {
+				Endpoint: endpoints.LogoutEndpoint,
+				Decode:   decodeRPCLogoutRequest,
+				Encode:   encodeRPCResponse,
+			},
+		},
+		logger: logger,
+	}
+}
+
+// ServeHTTP 支持单个请求和批量数组，通知不返回响应，全部为通知时返回204
+func (h *jsonRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		methodNotAllowedHandler(w, r)
+		return
+	}
+	ctx := jwtToContext(r.Context(), r)
+
+	var body json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxV1BodySize)).Decode(&body); err != nil {
+		h.write(w, rpcErrorResponse(nullID, jsonrpc.Error{Code: jsonrpc.ParseError, Message: err.Error()}))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		if resp, ok := h.call(ctx, body); ok {
+			h.write(w, resp)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		h.write(w, rpcErrorResponse(nullID, jsonrpc.Error{Code: jsonrpc.ParseError, Message: err.Error()}))
+		return
+	}
+	if len(batch) == 0 || len(batch) > endpoints.MaxBatchSize {
+		h.write(w, rpcErrorResponse(nullID, jsonrpc.Error{Code: jsonrpc.InvalidRequestError, Message: "batch must contain 1 to 100 requests"}))
+		return
+	}
+
+	responses := make([]rpcResponse, 0, len(batch))
+	for _, raw := range batch {
+		if resp, ok := h.call(ctx, raw); ok {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.write(w, responses)
+}
+
+// call 执行单个请求，ok为false表示该请求是通知，不需要响应
+func (h *jsonRPCHandler) call(ctx context.Context, raw json.RawMessage) (resp rpcResponse, ok bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return rpcErrorResponse(nullID, jsonrpc.Error{Code: jsonrpc.InvalidRequestError, Message: err.Error()}), true
+	}
+	id := req.ID
+	notification := id == nil
+	if notification {
+		id = nullID
+	}
+	if req.JSONRPC != jsonrpc.Version || req.Method == "" {
+		return rpcErrorResponse(id, jsonrpc.Error{Code: jsonrpc.InvalidRequestError, Message: `jsonrpc must be "2.0" and method must be set`}), true
+	}
+
+	result, err := h.invoke(ctx, req)
+	if err != nil {
+		h.logger.Log("transport", "jsonrpc", "method", req.Method, "err", err)
+	}
+	if notification {
+		return rpcResponse{}, false
+	}
+	if err != nil {
+		return rpcErrorResponse(id, rpcError(err)), true
+	}
+	return rpcResponse{JSONRPC: jsonrpc.Version, Result: result, ID: id}, true
+}
+
+// invoke 按方法名分发到对应的Endpoint
+func (h *jsonRPCHandler) invoke(ctx context.Context, req rpcRequest) (json.RawMessage, error) {
+	codec, ok := h.methods[req.Method]
+	decode := codec.Decode
+	if !ok {
+		codec = h.methods[rpcCalculateMethod]
+		decode = decodeRPCOperationRequest(req.Method)
+	}
+	ctx = context.WithValue(ctx, jsonrpc.ContextKeyRequestMethod, req.Method)
+
+	request, err := decode(ctx, req.Params)
+	if err != nil {
+		return nil, err
+	}
+	response, err := codec.Endpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Encode(ctx, response)
+}
+
+func (h *jsonRPCHandler) write(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", jsonrpc.ContentType)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Log("transport", "jsonrpc", "err", err)
+	}
+}
+
+func rpcErrorResponse(id json.RawMessage, err jsonrpc.Error) rpcResponse {
+	return rpcResponse{JSONRPC: jsonrpc.Version, Error: &err, ID: id}
+}
+
+// rpcErrorData JSON-RPC错误的data字段，与HTTP错误响应使用相同的错误码和附加信息
+type rpcErrorData struct {
+	Code    apperrors.Code `json:"code"`
+	Details interface{}    `json:"details,omitempty"`
+}
+
+// rpcError 将Endpoint返回的错误转换为JSON-RPC错误对象
+func rpcError(err error) jsonrpc.Error {
+	if e, ok := err.(jsonrpc.Error); ok {
+		return e
+	}
+	appErr := apperrors.From(err)
+	code := rpcApplicationError
+	switch appErr.Code {
+	case apperrors.InvalidArgument:
+		code = jsonrpc.InvalidParamsError
+	case apperrors.NotFound:
+		code = jsonrpc.MethodNotFoundError
+	case apperrors.Unauthenticated:
+		code = rpcUnauthenticated
+	case apperrors.PermissionDenied:
+		code = rpcPermissionDenied
+	case apperrors.RateLimited:
+		code = rpcRateLimitExceeded
+	case apperrors.Unavailable:
+		code = rpcUnavailable
+	case apperrors.Internal:
+		code = jsonrpc.InternalError
+	}
+	return jsonrpc.Error{
+		Code:    code,
+		Message: appErr.Message,
+		Data:    rpcErrorData{Code: appErr.Code, Details: appErr.Details},
+	}
+}
+
+// invalidParams 参数解析失败
+func invalidParams(err error) error {
+	return jsonrpc.Error{Code: jsonrpc.InvalidParamsError, Message: err.Error()}
+}
+
+// decodeRPCParams 解析对象形式的参数，params为空时保持零值
+func decodeRPCParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams(err)
+	}
+	return nil
+}
+
+func decodeRPCArithmeticRequest(_ context.Context, params json.RawMessage) (interface{}, error) {
+	var req endpoints.ArithmeticRequest
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// decodeRPCOperationRequest 以方法名为运算类型，参数可以是[a, b]或{"a":..,"b":..,"mode":..}
+func decodeRPCOperationRequest(operation string) jsonrpc.DecodeRequestFunc {
+	return func(_ context.Context, params json.RawMessage) (interface{}, error) {
+		req := endpoints.ArithmeticRequest{RequestType: operation}
+		if trimmed := bytes.TrimSpace(params); len(trimmed) > 0 && trimmed[0] == '[' {
+			var operands []json.Number
+			if err := json.Unmarshal(trimmed, &operands); err != nil {
+				return nil, invalidParams(err)
+			}
+			if len(operands) > 2 {
+				return nil, invalidParams(errors.New("at most 2 operands are allowed"))
+			}
+			operands = append(operands, "", "")
+			req.A, req.B = operands[0], operands[1]
+			return req, nil
+		}
+		if err := decodeRPCParams(params, &req); err != nil {
+			return nil, err
+		}
+		req.RequestType = operation
+		return req, nil
+	}
+}
+
+func decodeRPCEvaluateRequest(_ context.Context, params json.RawMessage) (interface{}, error) {
+	var req endpoints.EvaluateRequest
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// decodeRPCBatchRequest 参数可以是请求数组，也可以是{"items":[...],"concurrency":N}
+func decodeRPCBatchRequest(_ context.Context, params json.RawMessage) (interface{}, error) {
+	var req endpoints.BatchRequest
+	if trimmed := bytes.TrimSpace(params); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &req.Items); err != nil {
+			return nil, invalidParams(err)
+		}
+		return req, nil
+	}
+	var body struct {
+		Items       []endpoints.ArithmeticRequest `json:"items"`
+		Concurrency int                           `json:"concurrency"`
+	}
+	if err := decodeRPCParams(params, &body); err != nil {
+		return nil, err
+	}
+	req.Items, req.Concurrency = body.Items, body.Concurrency
+	return req, nil
+}
+
+func decodeRPCOperationsRequest(_ context.Context, _ json.RawMessage) (interface{}, error) {
+	return endpoints.OperationsRequest{}, nil
+}
+
+func decodeRPCHealthRequest(_ context.Context, _ json.RawMessage) (interface{}, error) {
+	return endpoints.HealthRequest{}, nil
+}
+
+func decodeRPCLoginRequest(_ context.Context, params json.RawMessage) (interface{}, error) {
+	var req endpoints.AuthRequest
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeRPCRefreshRequest(_ context.Context, params json.RawMessage) (interface{}, error) {
+	var req endpoints.RefreshRequest
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeRPCLogoutRequest(_ context.Context, params json.RawMessage) (interface{}, error) {
+	var req endpoints.LogoutRequest
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func encodeRPCResponse(_ context.Context, response interface{}) (json.RawMessage, error) {
+	return json.Marshal(response)
+}
+
+func encodeRPCArithmeticResponse(_ context.Context, response interface{}) (json.RawMessage, error) {
+	resp := response.(endpoints.ArithmeticResponse)
+	return json.Marshal(rpcArithmeticResult{Result: resp.Result, Mode: resp.Mode, Rational: resp.Rational})
+}
@@ -0,0 +1,446 @@
+package transports
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"learn/apperrors"
+	"learn/endpoints"
+	"learn/healths"
+	"learn/services"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+)
+
+//go:generate sh fetch_swagger_ui.sh
+
+//go:embed swagger.html
+var swaggerUI []byte
+
+// swaggerAssets Swagger UI的静态文件，由go generate从swagger-ui-dist下载，版本见swagger-ui/VERSION
+//
+//go:embed swagger-ui
+var swaggerAssets embed.FS
+
+// routeDoc 路由的文档信息，按路由名称关联，请求和响应使用对应结构体的零值描述
+type routeDoc struct {
+	Summary     string
+	Description string
+	Tag         string
+	Auth        bool
+	Deprecated  bool
+	Params      []paramDoc
+	Request     interface{}
+	Response    interface{}
+	// RequestTypes、ResponseTypes 请求体和响应支持的媒体类型，默认application/json
+	RequestTypes  []string
+	ResponseTypes []string
+	// OptionalAuth 接受但不要求bearer token，OptionalBody 请求体可以为空
+	OptionalAuth bool
+	OptionalBody bool
+}
+
+// paramDoc 路径或查询参数，Method不为空时只用于该请求方法
+type paramDoc struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Method      string
+}
+
+var arithmeticQueryParams = []paramDoc{
+	{Name: "request_type", In: "query", Description: "operation name or alias, see /operations", Required: true, Method: http.MethodGet},
+	{Name: "a", In: "query", Description: "first operand", Required: true, Method: http.MethodGet},
+	{Name: "b", In: "query", Description: "second operand, omitted for unary operations", Method: http.MethodGet},
+	{Name: "mode", In: "query", Description: "numeric mode: int (default), int64, float64 or rat", Method: http.MethodGet},
+}
+
+// routeDocs 各路由的文档，键为路由名称
+var routeDocs = map[string]routeDoc{
+	"calculate": {
+		Summary: "Calculate with a JSON body", Tag: "arithmetic", Auth: true,
+		Request: endpoints.ArithmeticRequest{}, Response: endpoints.ArithmeticResponse{},
+	},
+	"calculate-path": {
+		Summary: "Calculate with operands in the path", Tag: "arithmetic", Auth: true, Deprecated: true,
+		Description: "Deprecated alias of /v1/calculate.",
+		Params: []paramDoc{
+			{Name: "type", In: "path", Description: "operation name or alias", Required: true},
+			{Name: "a", In: "path", Description: "first operand", Required: true},
+			{Name: "b", In: "path", Description: "second operand", Required: true},
+			{Name: "mode", In: "query", Description: "numeric mode: int (default), int64, float64 or rat"},
+		},
+		Response: endpoints.ArithmeticResponse{},
+	},
+	"calculate-path-unary": {
+		Summary: "Calculate a unary operation with the operand in the path", Tag: "arithmetic", Auth: true, Deprecated: true,
+		Description: "Deprecated alias of /v1/calculate.",
+		Params: []paramDoc{
+			{Name: "type", In: "path", Description: "operation name or alias", Required: true},
+			{Name: "a", In: "path", Description: "operand", Required: true},
+			{Name: "mode", In: "query", Description: "numeric mode: int (default), int64, float64 or rat"},
+		},
+		Response: endpoints.ArithmeticResponse{},
+	},
+	"v1-calculate": {
+		Summary: "Calculate", Tag: "arithmetic", Auth: true,
+		Description:   "GET reads the query parameters, POST accepts JSON, form or protobuf bodies. The response format is negotiated with Accept.",
+		Params:        arithmeticQueryParams,
+		Request:       endpoints.ArithmeticRequest{},
+		RequestTypes:  []string{mediaJSON, mediaForm, mediaProtobuf},
+		Response:      v1CalculateResponse{},
+		ResponseTypes: []string{mediaJSON, mediaXML, mediaText, mediaMsgpack, mediaProtobuf},
+	},
+	"v1-operations": {
+		Summary: "List operations", Tag: "arithmetic",
+		Response:      endpoints.OperationsResponse{},
+		ResponseTypes: []string{mediaJSON, mediaXML, mediaText, mediaMsgpack},
+	},
+	"operations": {
+		Summary: "List operations", Tag: "arithmetic",
+		Response: endpoints.OperationsResponse{},
+	},
+	"batch": {
+		Summary: "Calculate a batch of requests", Tag: "arithmetic", Auth: true,
+		Description: "Results keep the request order. The rate limiter charges one token per item.",
+		Params:      []paramDoc{{Name: "concurrency", In: "query", Description: "number of items calculated concurrently, at most 8"}},
+		Request:     []endpoints.ArithmeticRequest{},
+		Response:    endpoints.BatchResponse{},
+	},
+	"evaluate": {
+		Summary: "Evaluate an infix expression", Tag: "arithmetic", Auth: true,
+		Description: "Each binary operator is authorized as the matching operation (Add, Subtract, Multiply, Divide, Pow) with the values of its operands.",
+		Request:     endpoints.EvaluateRequest{}, Response: endpoints.EvaluateResponse{},
+	},
+	"metrics": {
+		Summary: "Prometheus metrics", Tag: "operations",
+		ResponseTypes: []string{mediaText},
+	},
+	"rpc": {
+		Summary: "JSON-RPC 2.0", Tag: "arithmetic",
+		Description: "Method names are operation names or calculate, evaluate, batch, operations, health, login, refresh and logout. Batch arrays and notifications are supported.",
+		Request:     map[string]interface{}{}, Response: map[string]interface{}{},
+	},
+	"health": {
+		Summary: "Health check", Tag: "operations",
+		Response: endpoints.HealthResponse{},
+	},
+	"healthz": {
+		Summary: "Liveness check", Tag: "operations",
+		Description: "Runs the liveness checks. Responds 503 with the same report when any check is down.",
+		Response:    healths.Report{},
+	},
+	"readyz": {
+		Summary: "Readiness check", Tag: "operations",
+		Description: "Runs the liveness and readiness checks, such as the Consul registration and the tracer reporter. Responds 503 with the same report when any check is down.",
+		Response:    healths.Report{},
+	},
+	"login": {
+		Summary: "Log in and get an access and a refresh token", Tag: "auth",
+		Request: endpoints.AuthRequest{}, Response: endpoints.AuthResponse{},
+	},
+	"refresh": {
+		Summary: "Exchange a refresh token for new tokens", Tag: "auth",
+		Request: endpoints.RefreshRequest{}, Response: endpoints.AuthResponse{},
+	},
+	"logout": {
+		Summary: "Revoke the refresh token and the current access token", Tag: "auth", OptionalAuth: true,
+		Description: "Both the body and the bearer token are optional: the refresh token in the body and the access token in the Authorization header are revoked when present.",
+		Request:     endpoints.LogoutRequest{}, Response: endpoints.LogoutResponse{}, OptionalBody: true,
+	},
+	"jwks": {
+		Summary: "Public keys for verifying tokens", Tag: "auth",
+		Response: services.JSONWebKeySet{},
+	},
+	"openapi": {
+		Summary: "This OpenAPI document", Tag: "operations",
+		Response: map[string]interface{}{},
+	},
+	"docs": {
+		Summary: "Swagger UI", Tag: "operations",
+		ResponseTypes: []string{"text/html"},
+	},
+	"docs-assets": {
+		Summary: "Static files used by the Swagger UI page", Tag: "operations",
+		ResponseTypes: []string{"text/css", "application/javascript"},
+	},
+}
+
+// openAPIHandler 返回根据router中已注册路由生成的OpenAPI 3文档，首次请求时生成
+func openAPIHandler(router *mux.Router, logger log.Logger) http.Handler {
+	var (
+		once sync.Once
+		body []byte
+		err  error
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			body, err = json.MarshalIndent(buildOpenAPI(router), "", "  ")
+		})
+		if err != nil {
+			errorEncoder(logger, encodeError)(r.Context(), err, w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json;charset=utf-8")
+		w.Write(body)
+	})
+}
+
+// swaggerUIHandler 内嵌的Swagger UI页面，读取/openapi.json，静态文件未下载时返回503
+func swaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := fs.Stat(swaggerAssets, "swagger-ui/swagger-ui-bundle.js"); err != nil {
+		encodeError(r.Context(), apperrors.New(apperrors.Unavailable, "swagger ui assets are missing, run go generate ./transports"), w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	w.Write(swaggerUI)
+}
+
+// swaggerAssetsHandler 提供Swagger UI页面引用的内嵌静态文件，不依赖外部CDN
+func swaggerAssetsHandler() http.Handler {
+	assets, err := fs.Sub(swaggerAssets, "swagger-ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/docs/", http.FileServer(http.FS(assets)))
+}
+
+var pathVarPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+
+// buildOpenAPI 遍历路由生成文档，没有文档信息的路由只描述路径参数
+func buildOpenAPI(router *mux.Router) map[string]interface{} {
+	schemas := newSchemaRegistry()
+	paths := map[string]map[string]interface{}{}
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || route.GetHandler() == nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{http.MethodGet}
+		}
+		doc := routeDocs[route.GetName()]
+		path := pathVarPattern.ReplaceAllString(tpl, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		for _, method := range methods {
+			paths[path][strings.ToLower(method)] = buildOperation(route.GetName(), method, tpl, doc, schemas)
+		}
+		return nil
+	})
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Arithmetic service",
+			"version":     "1.0.0",
+			"description": "Arithmetic operations over REST, JSON-RPC and gRPC. Errors use the envelope {\"error\":{\"code\",\"message\",\"details\"}}.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func buildOperation(name, method, tpl string, doc routeDoc, schemas *schemaRegistry) map[string]interface{} {
+	op := map[string]interface{}{}
+	if name != "" {
+		op["operationId"] = name + "-" + strings.ToLower(method)
+	}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+	if doc.Description != "" {
+		op["description"] = doc.Description
+	}
+	if doc.Tag != "" {
+		op["tags"] = []string{doc.Tag}
+	}
+	if doc.Deprecated {
+		op["deprecated"] = true
+	}
+	switch {
+	case doc.Auth:
+		op["security"] = []map[string][]string{{"bearerAuth": {}}}
+	case doc.OptionalAuth:
+		// 空的安全要求表示可以不带token
+		op["security"] = []map[string][]string{{}, {"bearerAuth": {}}}
+	}
+
+	// 路径参数以路由模板为准，文档中只补充描述
+	var params []map[string]interface{}
+	documented := map[string]paramDoc{}
+	for _, p := range doc.Params {
+		documented[p.In+":"+p.Name] = p
+	}
+	for _, m := range pathVarPattern.FindAllStringSubmatch(tpl, -1) {
+		p := documented["path:"+m[1]]
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true, "description": p.Description,
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	for _, p := range doc.Params {
+		if p.In != "query" || (p.Method != "" && p.Method != method) {
+			continue
+		}
+		params = append(params, map[string]interface{}{
+			"name": p.Name, "in": "query", "required": p.Required, "description": p.Description,
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.Request != nil && method != http.MethodGet {
+		op["requestBody"] = map[string]interface{}{
+			"required": !doc.OptionalBody,
+			"content":  content(doc.RequestTypes, schemas.schemaOf(reflect.TypeOf(doc.Request))),
+		}
+	}
+
+	responses := map[string]interface{}{}
+	ok := map[string]interface{}{"description": "OK"}
+	if doc.Response != nil || len(doc.ResponseTypes) > 0 {
+		var schema interface{} = map[string]string{"type": "string"}
+		if doc.Response != nil {
+			schema = schemas.schemaOf(reflect.TypeOf(doc.Response))
+		}
+		ok["content"] = content(doc.ResponseTypes, schema)
+	}
+	responses["200"] = ok
+	errorRef := map[string]interface{}{
+		"description": "Error envelope, the HTTP status follows the error code",
+		"content":     content(nil, schemas.schemaOf(reflect.TypeOf(errorResponse{}))),
+	}
+	if doc.Auth {
+		responses["401"] = errorRef
+	}
+	responses["default"] = errorRef
+	op["responses"] = responses
+	return op
+}
+
+func content(types []string, schema interface{}) map[string]interface{} {
+	if len(types) == 0 {
+		types = []string{mediaJSON}
+	}
+	c := map[string]interface{}{}
+	for _, t := range types {
+		c[t] = map[string]interface{}{"schema": schema}
+	}
+	return c
+}
+
+// schemaRegistry 根据Go类型生成JSON Schema，命名结构体放入components/schemas并以$ref引用
+type schemaRegistry struct {
+	schemas map[string]interface{}
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]interface{}{}}
+}
+
+var (
+	jsonNumberType   = reflect.TypeOf(json.Number(""))
+	resultNumberType = reflect.TypeOf(resultNumber(""))
+	rawMessageType   = reflect.TypeOf(json.RawMessage(nil))
+)
+
+func (s *schemaRegistry) schemaOf(t reflect.Type) interface{} {
+	switch t {
+	case jsonNumberType, resultNumberType:
+		return map[string]string{"type": "number"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]string{"type": "string"}
+	case reflect.Bool:
+		return map[string]string{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]string{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]string{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := s.schemas[name]; !ok {
+			// 先占位，避免递归类型无限展开
+			s.schemas[name] = nil
+			s.schemas[name] = s.structSchema(t)
+		}
+		return map[string]string{"$ref": "#/components/schemas/" + name}
+	}
+	// interface{}等无法确定类型的字段
+	return map[string]interface{}{}
+}
+
+func (s *schemaRegistry) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Type.Kind() == reflect.Func {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = f.Name
+		}
+		omitempty := false
+		for _, opt := range parts[1:] {
+			omitempty = omitempty || opt == "omitempty"
+		}
+		props[name] = s.schemaOf(f.Type)
+		if !omitempty && f.Type.Kind() != reflect.Ptr && f.Type.Kind() != reflect.Interface {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName 结构体名称首字母大写，如errorResponse为ErrorResponse
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	if len(name) == 0 {
+		return "Object"
+	}
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
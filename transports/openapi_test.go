@@ -0,0 +1,50 @@
+package transports
+
+import (
+	"bytes"
+	"context"
+	"learn/endpoints"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestOpenAPILogout(t *testing.T) {
+	op := buildOperation("logout", http.MethodPost, "/logout", routeDocs["logout"], newSchemaRegistry())
+
+	wantSecurity := []map[string][]string{{}, {"bearerAuth": {}}}
+	if security := op["security"]; !reflect.DeepEqual(security, wantSecurity) {
+		t.Errorf("security = %v, want %v", security, wantSecurity)
+	}
+	body, _ := op["requestBody"].(map[string]interface{})
+	if body == nil || body["required"] != false {
+		t.Errorf("requestBody = %v, want an optional body", body)
+	}
+	if _, ok := op["responses"].(map[string]interface{})["401"]; ok {
+		t.Error("logout documents 401 although the bearer token is optional")
+	}
+
+	op = buildOperation("evaluate", http.MethodPost, "/evaluate", routeDocs["evaluate"], newSchemaRegistry())
+	if security := op["security"]; !reflect.DeepEqual(security, []map[string][]string{{"bearerAuth": {}}}) {
+		t.Errorf("evaluate security = %v, want bearer auth", security)
+	}
+	if body, _ := op["requestBody"].(map[string]interface{}); body == nil || body["required"] != true {
+		t.Errorf("evaluate requestBody = %v, want a required body", body)
+	}
+}
+
+func TestSwaggerUIVendored(t *testing.T) {
+	if bytes.Contains(swaggerUI, []byte("//unpkg.com")) || bytes.Contains(swaggerUI, []byte("https://")) {
+		t.Error("swagger.html loads assets from another host")
+	}
+	h := MakeHttpHandler(context.Background(), endpoints.ArithmeticEndpoints{}, log.NewNopLogger())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/VERSION", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) == "" {
+		t.Errorf("GET /docs/VERSION = %d %q, want the embedded swagger-ui-dist version", w.Code, w.Body.String())
+	}
+}
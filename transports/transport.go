@@ -4,9 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"learn/apperrors"
 	"learn/endpoints"
+	"learn/healths"
+	"learn/services"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -16,9 +21,11 @@ import (
 )
 
 var (
-	ErrorBadRequest = errors.New("invalid request parameter")
+	ErrorBadRequest = apperrors.New(apperrors.InvalidArgument, "invalid request parameter")
 )
 
+// decodeArithmeticRequest 从路径参数中读取运算类型和操作数，数值模式通过查询参数指定，如?mode=rat
+// 操作数按原样传递，由运算Endpoint根据数值模式校验
 func decodeArithmeticRequest(_ context.Context, r *http.Request) (interface{}, error) {
 
 	vars := mux.Vars(r)
@@ -34,21 +41,141 @@ func decodeArithmeticRequest(_ context.Context, r *http.Request) (interface{}, e
 		return nil, ErrorBadRequest
 	}
 
-	pb, ok := vars["b"]
+	// 一元运算的路由中没有b
+	pb := vars["b"]
 
-	if !ok {
+	return endpoints.ArithmeticRequest{
+		RequestType: requestType,
+		Mode:        r.URL.Query().Get("mode"),
+		A:           json.Number(pa),
+		B:           json.Number(pb),
+	}, nil
+}
+
+// arithmeticBody POST /calculate的请求体，操作数可以是JSON数字或数字字符串
+type arithmeticBody struct {
+	RequestType string          `json:"request_type"`
+	Mode        string          `json:"mode"`
+	A           json.RawMessage `json:"a"`
+	B           json.RawMessage `json:"b"`
+}
+
+// decodeArithmeticBodyRequest 从JSON请求体中读取运算请求，不允许未知字段
+func decodeArithmeticBodyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body arithmeticBody
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxV1BodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		return nil, bodyError(err)
+	}
+	if dec.More() {
 		return nil, ErrorBadRequest
 	}
-	a, _ := strconv.Atoi(pa)
-	b, _ := strconv.Atoi(pb)
+
+	verr := &endpoints.ValidationError{}
+	a, ok := operandFromJSON(body.A)
+	if !ok {
+		verr.Add("a", "must be a number or a numeric string")
+	}
+	b, ok := operandFromJSON(body.B)
+	if !ok {
+		verr.Add("b", "must be a number or a numeric string")
+	}
+	if err := verr.Err(); err != nil {
+		return nil, err
+	}
 
 	return endpoints.ArithmeticRequest{
-		RequestType: requestType,
+		RequestType: body.RequestType,
+		Mode:        body.Mode,
 		A:           a,
 		B:           b,
 	}, nil
 }
 
+// operandFromJSON 接受JSON数字、字符串或null（视为未提供）
+func operandFromJSON(raw json.RawMessage) (json.Number, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", true
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", false
+		}
+		return json.Number(strings.TrimSpace(s)), true
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", false
+	}
+	return n, true
+}
+
+// bodyError 将请求体解析错误转换为字段错误，无法定位字段时返回参数错误
+func bodyError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		verr := &endpoints.ValidationError{}
+		verr.Add(typeErr.Field, "must be a %s", typeErr.Type.Kind())
+		return verr
+	}
+	// DisallowUnknownFields的错误没有独立类型，格式为json: unknown field "x"
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		verr := &endpoints.ValidationError{}
+		verr.Add(strings.Trim(strings.TrimPrefix(msg, "json: unknown field "), `"`), "is not a known field")
+		return verr
+	}
+	return apperrors.Wrap(apperrors.InvalidArgument, err)
+}
+
+func decodeOperationsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return endpoints.OperationsRequest{}, nil
+}
+
+func decodeHealthCheckRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return endpoints.HealthRequest{}, nil
+}
+
+func decodeHealthReportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return endpoints.HealthReportRequest{}, nil
+}
+
+// encodeHealthReportResponse 检查全部通过时返回200，否则返回503，响应体均为检查报告
+func encodeHealthReportResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	report := response.(healths.Report)
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if !report.Up() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+// decodeBatchRequest 请求体为ArithmeticRequest数组，并发数通过查询参数concurrency指定
+func decodeBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var batchRequest endpoints.BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxV1BodySize)).Decode(&batchRequest.Items); err != nil {
+		return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
+	}
+	if c := r.URL.Query().Get("concurrency"); c != "" {
+		concurrency, err := strconv.Atoi(c)
+		if err != nil {
+			return nil, ErrorBadRequest
+		}
+		batchRequest.Concurrency = concurrency
+	}
+	return batchRequest, nil
+}
+
+func decodeEvaluateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var evaluateRequest endpoints.EvaluateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxV1BodySize)).Decode(&evaluateRequest); err != nil {
+		return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
+	}
+	return evaluateRequest, nil
+}
+
 func encodeArithmeticResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
 	return json.NewEncoder(w).Encode(response)
@@ -56,46 +183,219 @@ func encodeArithmeticResponse(ctx context.Context, w http.ResponseWriter, respon
 
 func MakeHttpHandler(ctx context.Context, endpoints endpoints.ArithmeticEndpoints, logger log.Logger) http.Handler {
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	r.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 
 	options := []kithttp.ServerOption{
-		kithttp.ServerErrorLogger(logger),
-		kithttp.ServerErrorEncoder(kithttp.DefaultErrorEncoder),
+		kithttp.ServerErrorEncoder(errorEncoder(logger, encodeError)),
 	}
 
-	r.Methods("POST").Path("/calculate/{type}/{a}/{b}").Handler(kithttp.NewServer(
+	// 需要身份认证的接口，从请求头中提取token
+	authOptions := append(options, kithttp.ServerBefore(jwtToContext))
+
+	r.Methods("POST").Path("/calculate").Name("calculate").Handler(kithttp.NewServer(
+		endpoints.ArithmeticEndpoint,
+		decodeArithmeticBodyRequest,
+		encodeArithmeticResponse,
+		authOptions...,
+	))
+	// 路径参数形式的旧接口，已由/v1/calculate替代
+	r.Methods("POST").Path("/calculate/{type}/{a}/{b}").Name("calculate-path").Handler(deprecated("/v1/calculate", kithttp.NewServer(
 		endpoints.ArithmeticEndpoint,
 		decodeArithmeticRequest,
 		encodeArithmeticResponse,
+		authOptions...,
+	)))
+	r.Methods("POST").Path("/calculate/{type}/{a}").Name("calculate-path-unary").Handler(deprecated("/v1/calculate", kithttp.NewServer(
+		endpoints.ArithmeticEndpoint,
+		decodeArithmeticRequest,
+		encodeArithmeticResponse,
+		authOptions...,
+	)))
+
+	// /v1接口，GET使用查询参数，POST支持JSON、表单和protobuf请求体，响应格式由Accept协商
+	v1Options := []kithttp.ServerOption{
+		kithttp.ServerErrorEncoder(errorEncoder(logger, encodeV1Error)),
+	}
+	v1 := r.PathPrefix("/v1").Subrouter()
+	v1.Methods("GET", "POST").Path("/calculate").Name("v1-calculate").Handler(kithttp.NewServer(
+		endpoints.ArithmeticEndpoint,
+		decodeV1CalculateRequest,
+		encodeV1CalculateResponse,
+		append(v1Options,
+			kithttp.ServerBefore(jwtToContext),
+			kithttp.ServerBefore(negotiateBefore(mediaJSON, mediaXML, mediaText, mediaMsgpack, mediaProtobuf)),
+		)...,
+	))
+	v1.Methods("GET").Path("/operations").Name("v1-operations").Handler(kithttp.NewServer(
+		endpoints.OperationsEndpoint,
+		decodeV1OperationsRequest,
+		encodeV1OperationsResponse,
+		append(v1Options,
+			kithttp.ServerBefore(negotiateBefore(mediaJSON, mediaXML, mediaText, mediaMsgpack)),
+		)...,
+	))
+
+	r.Methods("GET").Path("/operations").Name("operations").Handler(kithttp.NewServer(
+		endpoints.OperationsEndpoint,
+		decodeOperationsRequest,
+		encodeArithmeticResponse,
 		options...,
 	))
-	r.Path("/metrics").Handler(promhttp.Handler())
 
-	r.Methods("GET").Path("/health").Handler(kithttp.NewServer(
+	r.Methods("POST").Path("/calculate/batch").Name("batch").Handler(kithttp.NewServer(
+		endpoints.BatchEndpoint,
+		decodeBatchRequest,
+		encodeArithmeticResponse,
+		authOptions...,
+	))
+
+	r.Methods("POST").Path("/evaluate").Name("evaluate").Handler(kithttp.NewServer(
+		endpoints.EvaluateEndpoint,
+		decodeEvaluateRequest,
+		encodeArithmeticResponse,
+		authOptions...,
+	))
+	r.Methods("GET").Path("/metrics").Name("metrics").Handler(promhttp.Handler())
+
+	// JSON-RPC 2.0接口，复用上述Endpoint
+	r.Methods("POST").Path("/rpc").Name("rpc").Handler(MakeJSONRPCHandler(endpoints, logger))
+
+	r.Methods("GET").Path("/health").Name("health").Handler(kithttp.NewServer(
 		endpoints.HealthCheckEndpoint,
-		decodeArithmeticRequest,
+		decodeHealthCheckRequest,
 		encodeArithmeticResponse,
 		options...,
 	))
+	// 存活检查和就绪检查，检查失败时返回503
+	r.Methods("GET").Path("/healthz").Name("healthz").Handler(kithttp.NewServer(
+		endpoints.LivenessEndpoint,
+		decodeHealthReportRequest,
+		encodeHealthReportResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/readyz").Name("readyz").Handler(kithttp.NewServer(
+		endpoints.ReadinessEndpoint,
+		decodeHealthReportRequest,
+		encodeHealthReportResponse,
+		options...,
+	))
 
-	r.Methods("POST").Path("/login").Handler(kithttp.NewServer(
+	r.Methods("POST").Path("/login").Name("login").Handler(kithttp.NewServer(
 		endpoints.AuthEndpoint,
 		decodeLoginRequest,
 		encodeLoginResponse,
 		options...,
 	))
 
+	r.Methods("POST").Path("/refresh").Name("refresh").Handler(kithttp.NewServer(
+		endpoints.RefreshEndpoint,
+		decodeRefreshRequest,
+		encodeLoginResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/logout").Name("logout").Handler(kithttp.NewServer(
+		endpoints.LogoutEndpoint,
+		decodeLogoutRequest,
+		encodeLoginResponse,
+		authOptions...,
+	))
+
+	r.Methods("GET").Path("/.well-known/jwks.json").Name("jwks").Handler(kithttp.NewServer(
+		endpoints.JWKSEndpoint,
+		decodeJWKSRequest,
+		encodeJWKSResponse,
+		options...,
+	))
+
+	// OpenAPI文档根据上述路由生成
+	r.Methods("GET").Path("/openapi.json").Name("openapi").Handler(openAPIHandler(r, logger))
+	r.Methods("GET").Path("/docs").Name("docs").HandlerFunc(swaggerUIHandler)
+	r.Methods("GET").Path("/docs/{file}").Name("docs-assets").Handler(swaggerAssetsHandler())
+
 	return r
 }
 
 func decodeLoginRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var loginRequest endpoints.AuthRequest
-	if err := json.NewDecoder(r.Body).Decode(&loginRequest); err != nil {
-		return nil, err
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxV1BodySize)).Decode(&loginRequest); err != nil {
+		return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
 	}
 	return loginRequest, nil
 }
 
+func decodeRefreshRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var refreshRequest endpoints.RefreshRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxV1BodySize)).Decode(&refreshRequest); err != nil {
+		return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
+	}
+	return refreshRequest, nil
+}
+
+func decodeLogoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var logoutRequest endpoints.LogoutRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxV1BodySize)).Decode(&logoutRequest); err != nil && err != io.EOF {
+		return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
+	}
+	return logoutRequest, nil
+}
+
+func decodeJWKSRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return endpoints.JWKSRequest{}, nil
+}
+
+func encodeJWKSResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return json.NewEncoder(w).Encode(response)
+}
+
 func encodeLoginResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
 	return json.NewEncoder(w).Encode(response)
 }
+
+// jwtToContext 从Authorization请求头中提取Bearer token并放入context
+func jwtToContext(ctx context.Context, r *http.Request) context.Context {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ctx
+	}
+	return context.WithValue(ctx, services.JWTTokenContextKey, strings.TrimSpace(parts[1]))
+}
+
+// errorResponse 统一的错误响应结构：{"error":{"code":..,"message":..,"details":..}}
+type errorResponse struct {
+	Error *apperrors.Error `json:"error"`
+}
+
+// errorEncoder 记录原错误后由encode返回错误响应，响应中的Internal错误只有通用信息
+func errorEncoder(logger log.Logger, encode kithttp.ErrorEncoder) kithttp.ErrorEncoder {
+	return func(ctx context.Context, err error, w http.ResponseWriter) {
+		logger.Log("transport", "http", "err", err)
+		encode(ctx, err, w)
+	}
+}
+
+// encodeError 按错误码返回对应的HTTP状态码和统一的JSON错误响应，
+// token校验失败时附带WWW-Authenticate响应头
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	appErr := apperrors.From(err)
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	if appErr.Code == apperrors.Unauthenticated && !errors.Is(err, services.ErrInvalidCredentials) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	w.WriteHeader(apperrors.HTTPStatus(appErr.Code))
+	json.NewEncoder(w).Encode(errorResponse{Error: appErr})
+}
+
+// notFoundHandler 未匹配路由时返回统一的错误响应
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	encodeError(r.Context(), apperrors.New(apperrors.NotFound, "no route for "+r.URL.Path), w)
+}
+
+// methodNotAllowedHandler 路由存在但请求方法不匹配时返回统一的错误响应
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	encodeError(r.Context(), apperrors.New(apperrors.MethodNotAllowed, r.Method+" is not allowed for "+r.URL.Path), w)
+}
@@ -0,0 +1,136 @@
+package transports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"learn/apperrors"
+	"learn/endpoints"
+	"learn/healths"
+	"learn/pb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// oversized 超过maxV1BodySize的JSON请求体，字符串未结束，只有读完整个请求体才能解码
+func oversized() string {
+	return `{"expr": "` + strings.Repeat("1+", maxV1BodySize/2+1)
+}
+
+func TestDecodeBodyLimit(t *testing.T) {
+	decoders := map[string]func(context.Context, *http.Request) (interface{}, error){
+		"calculate": decodeArithmeticBodyRequest,
+		"batch":     decodeBatchRequest,
+		"evaluate":  decodeEvaluateRequest,
+		"login":     decodeLoginRequest,
+		"refresh":   decodeRefreshRequest,
+		"logout":    decodeLogoutRequest,
+	}
+	for name, decode := range decoders {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(oversized()))
+		_, err := decode(context.Background(), r)
+		if err == nil || !strings.Contains(err.Error(), "request body too large") {
+			t.Errorf("%s: error = %v, want request body too large", name, err)
+			continue
+		}
+		if code := apperrors.CodeOf(err); code != apperrors.InvalidArgument {
+			t.Errorf("%s: code = %s, want %s", name, code, apperrors.InvalidArgument)
+		}
+	}
+}
+
+func TestJSONRPCBodyLimit(t *testing.T) {
+	h := MakeJSONRPCHandler(endpoints.ArithmeticEndpoints{}, log.NewNopLogger())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(oversized())))
+
+	var resp struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error.Code != -32700 || !strings.Contains(resp.Error.Message, "request body too large") {
+		t.Errorf("error = %+v, want a parse error for the body size", resp.Error)
+	}
+}
+
+func TestEncodeHealthReportResponse(t *testing.T) {
+	tests := []struct {
+		report healths.Report
+		status int
+	}{
+		{healths.Report{Status: healths.StatusUp}, http.StatusOK},
+		{healths.Report{Status: healths.StatusDown, Checks: []healths.Result{{Name: "consul", Status: healths.StatusDown}}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		if err := encodeHealthReportResponse(context.Background(), w, tt.report); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.report.Status, w.Code, tt.status)
+		}
+		var got healths.Report
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.Status != tt.report.Status {
+			t.Errorf("%s: body = %+v, %v, want the report", tt.report.Status, got, err)
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", cc)
+		}
+	}
+}
+
+func TestInternalErrorHidden(t *testing.T) {
+	failing := func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.New("dial tcp 10.0.0.5:6379: connection refused")
+	}
+	eps := endpoints.ArithmeticEndpoints{OperationsEndpoint: failing, HealthCheckEndpoint: failing}
+	var logs bytes.Buffer
+	logger := log.NewLogfmtLogger(&logs)
+
+	w := httptest.NewRecorder()
+	MakeHttpHandler(context.Background(), eps, logger).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/operations", nil))
+	var httpResp errorResponse
+	if err := json.NewDecoder(w.Body).Decode(&httpResp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusInternalServerError || httpResp.Error.Message != "internal error" {
+		t.Errorf("http: %d %+v, want 500 with a generic message", w.Code, httpResp.Error)
+	}
+
+	w = httptest.NewRecorder()
+	MakeJSONRPCHandler(eps, logger).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rpc",
+		strings.NewReader(`{"jsonrpc":"2.0","method":"operations","id":1}`)))
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(w.Body).Decode(&rpcResp); err != nil {
+		t.Fatal(err)
+	}
+	if rpcResp.Error == nil || rpcResp.Error.Message != "internal error" {
+		t.Errorf("jsonrpc: error = %+v, want a generic message", rpcResp.Error)
+	}
+
+	_, err := MakeGRPCServer(eps, logger).HealthCheck(context.Background(), &pb.HealthRequest{})
+	if s, _ := status.FromError(err); s.Code() != codes.Internal || s.Message() != "internal error" {
+		t.Errorf("grpc: error = %v, want Internal with a generic message", err)
+	}
+
+	for _, transport := range []string{"http", "jsonrpc", "grpc"} {
+		if !strings.Contains(logs.String(), "transport="+transport) {
+			t.Errorf("%s: original error not logged:\n%s", transport, logs.String())
+		}
+	}
+	if n := strings.Count(logs.String(), "connection refused"); n != 3 {
+		t.Errorf("original error logged %d times, want once per transport:\n%s", n, logs.String())
+	}
+}
@@ -0,0 +1,356 @@
+package transports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"learn/apperrors"
+	"learn/endpoints"
+	"learn/pb"
+	"learn/services"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// /v1接口支持的媒体类型
+const (
+	mediaJSON     = "application/json"
+	mediaXML      = "application/xml"
+	mediaText     = "text/plain"
+	mediaMsgpack  = "application/msgpack"
+	mediaProtobuf = "application/x-protobuf"
+	mediaForm     = "application/x-www-form-urlencoded"
+)
+
+// mediaAliases 等价的媒体类型
+var mediaAliases = map[string]string{
+	"text/xml":                        mediaXML,
+	"application/x-msgpack":           mediaMsgpack,
+	"application/protobuf":            mediaProtobuf,
+	"application/vnd.google.protobuf": mediaProtobuf,
+}
+
+// maxV1BodySize 请求体大小上限，各接口的JSON请求体同样受此限制
+const maxV1BodySize = 1 << 20
+
+var (
+	ErrNotAcceptable        = apperrors.New(apperrors.NotAcceptable, "none of the accepted media types can be produced")
+	ErrUnsupportedMediaType = apperrors.New(apperrors.UnsupportedMediaType, "unsupported request content type")
+)
+
+type mediaContextKey struct{}
+
+// negotiate 根据Accept请求头从offers中选择响应格式，q值相同时按offers顺序优先
+// Accept为空时返回offers[0]，没有可接受的格式时返回空字符串
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		typ, sub string
+		q        float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		media, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if alias, ok := mediaAliases[media]; ok {
+			media = alias
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		typ := strings.SplitN(media, "/", 2)
+		if len(typ) != 2 {
+			continue
+		}
+		ranges = append(ranges, mediaRange{typ: typ[0], sub: typ[1], q: q})
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		typ := strings.SplitN(offer, "/", 2)
+		// 取最具体的匹配范围的q值
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ[0] && r.sub == typ[1]:
+				s = 2
+			case r.typ == typ[0] && r.sub == "*":
+				s = 1
+			case r.typ == "*" && r.sub == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// negotiateBefore 协商响应格式并放入context，offers的第一个为默认格式
+func negotiateBefore(offers ...string) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		return context.WithValue(ctx, mediaContextKey{}, negotiate(r.Header.Get("Accept"), offers))
+	}
+}
+
+// mediaFromContext 返回协商得到的响应格式，未协商或协商失败时返回空字符串
+func mediaFromContext(ctx context.Context) string {
+	media, _ := ctx.Value(mediaContextKey{}).(string)
+	return media
+}
+
+// decodeV1CalculateRequest GET从查询参数读取运算请求，POST根据Content-Type解析JSON、表单或protobuf请求体
+func decodeV1CalculateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	if mediaFromContext(ctx) == "" {
+		return nil, ErrNotAcceptable
+	}
+	if r.Method == http.MethodGet {
+		return arithmeticRequestFromValues(r.URL.Query())
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	media := mediaJSON
+	if contentType != "" {
+		var err error
+		if media, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, ErrUnsupportedMediaType
+		}
+		if alias, ok := mediaAliases[media]; ok {
+			media = alias
+		}
+	}
+
+	switch media {
+	case mediaJSON:
+		return decodeArithmeticBodyRequest(ctx, r)
+	case mediaForm:
+		r.Body = http.MaxBytesReader(nil, r.Body, maxV1BodySize)
+		if err := r.ParseForm(); err != nil {
+			return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
+		}
+		return arithmeticRequestFromValues(r.PostForm)
+	case mediaProtobuf:
+		data, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxV1BodySize))
+		if err != nil {
+			return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
+		}
+		var req pb.ArithmeticRequest
+		if err := proto.Unmarshal(data, &req); err != nil {
+			return nil, apperrors.Wrap(apperrors.InvalidArgument, err)
+		}
+		return endpoints.ArithmeticRequest{
+			RequestType: req.RequestType,
+			Mode:        req.Mode,
+			A:           json.Number(req.A),
+			B:           json.Number(req.B),
+		}, nil
+	}
+	return nil, ErrUnsupportedMediaType
+}
+
+// arithmeticRequestFromValues 从查询参数或表单中读取运算请求，未知参数和重复参数视为字段错误
+func arithmeticRequestFromValues(values url.Values) (interface{}, error) {
+	verr := &endpoints.ValidationError{}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case "request_type", "mode", "a", "b":
+			if len(values[key]) > 1 {
+				verr.Add(key, "must be given only once")
+			}
+		default:
+			verr.Add(key, "is not a known parameter")
+		}
+	}
+	if err := verr.Err(); err != nil {
+		return nil, err
+	}
+
+	return endpoints.ArithmeticRequest{
+		RequestType: values.Get("request_type"),
+		Mode:        values.Get("mode"),
+		A:           json.Number(strings.TrimSpace(values.Get("a"))),
+		B:           json.Number(strings.TrimSpace(values.Get("b"))),
+	}, nil
+}
+
+func decodeV1OperationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	if mediaFromContext(ctx) == "" {
+		return nil, ErrNotAcceptable
+	}
+	return endpoints.OperationsRequest{}, nil
+}
+
+// resultNumber 运算结果，JSON中为数字，XML和纯文本中为字符串，msgpack中整数编码为int，其余为float
+type resultNumber json.Number
+
+func (n resultNumber) MarshalJSON() ([]byte, error) {
+	return []byte(n), nil
+}
+
+func (n resultNumber) MarshalText() ([]byte, error) {
+	return []byte(n), nil
+}
+
+func (n resultNumber) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return enc.EncodeInt(i)
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return enc.EncodeString(string(n))
+	}
+	return enc.EncodeFloat64(f)
+}
+
+// v1CalculateResponse /v1/calculate的响应
+type v1CalculateResponse struct {
+	XMLName  xml.Name     `json:"-" xml:"calculation"`
+	Result   resultNumber `json:"result" xml:"result"`
+	Mode     string       `json:"mode" xml:"mode"`
+	Rational string       `json:"rational,omitempty" xml:"rational,omitempty"`
+}
+
+func (resp v1CalculateResponse) plainText() string {
+	return string(resp.Result)
+}
+
+func (resp v1CalculateResponse) protoMessage() proto.Message {
+	return &pb.ArithmeticResponse{Result: string(resp.Result), Mode: resp.Mode, Rational: resp.Rational}
+}
+
+// v1OperationsResponse /v1/operations的响应
+type v1OperationsResponse struct {
+	XMLName    xml.Name              `json:"-" xml:"operations"`
+	Operations []endpoints.Operation `json:"operations" xml:"operation"`
+}
+
+func (resp v1OperationsResponse) plainText() string {
+	lines := make([]string, len(resp.Operations))
+	for i, op := range resp.Operations {
+		lines[i] = fmt.Sprintf("%s/%d %s", op.Name, op.Arity, strings.Join(op.Modes, ","))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// v1ErrorResponse 错误响应，与其他接口的JSON结构一致
+type v1ErrorResponse struct {
+	XMLName xml.Name         `json:"-" xml:"error"`
+	Error   *apperrors.Error `json:"error" xml:"-"`
+	Code    apperrors.Code   `json:"-" xml:"code"`
+	Message string           `json:"-" xml:"message"`
+}
+
+func (resp v1ErrorResponse) plainText() string {
+	return fmt.Sprintf("%s: %s", resp.Code, resp.Message)
+}
+
+func encodeV1CalculateResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoints.ArithmeticResponse)
+	mode := resp.Mode
+	if mode == "" {
+		mode = services.ModeInt
+	}
+	return encodeNegotiated(ctx, w, http.StatusOK, v1CalculateResponse{
+		Result:   resultNumber(resp.Result),
+		Mode:     mode,
+		Rational: resp.Rational,
+	})
+}
+
+func encodeV1OperationsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoints.OperationsResponse)
+	return encodeNegotiated(ctx, w, http.StatusOK, v1OperationsResponse{Operations: resp.Operations})
+}
+
+// encodeV1Error 错误响应按协商的格式编码，无法协商或格式不支持错误结构时使用JSON
+func encodeV1Error(ctx context.Context, err error, w http.ResponseWriter) {
+	appErr := apperrors.From(err)
+	if appErr.Code == apperrors.Unauthenticated && !errors.Is(err, services.ErrInvalidCredentials) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	resp := v1ErrorResponse{Error: appErr, Code: appErr.Code, Message: appErr.Message}
+	if media := mediaFromContext(ctx); media == "" || media == mediaProtobuf {
+		ctx = context.WithValue(ctx, mediaContextKey{}, mediaJSON)
+	}
+	encodeNegotiated(ctx, w, apperrors.HTTPStatus(appErr.Code), resp)
+}
+
+// encodeNegotiated 按context中协商的格式编码响应
+func encodeNegotiated(ctx context.Context, w http.ResponseWriter, status int, response interface{}) error {
+	var (
+		body []byte
+		err  error
+	)
+	media := mediaFromContext(ctx)
+	switch media {
+	case mediaXML:
+		body, err = xml.Marshal(response)
+		body = append([]byte(xml.Header), body...)
+	case mediaText:
+		body = []byte(response.(interface{ plainText() string }).plainText() + "\n")
+	case mediaMsgpack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		err = enc.Encode(response)
+		body = buf.Bytes()
+	case mediaProtobuf:
+		body, err = proto.Marshal(response.(interface{ protoMessage() proto.Message }).protoMessage())
+	default:
+		media = mediaJSON
+		body, err = json.Marshal(response)
+		body = append(body, '\n')
+	}
+	if err != nil {
+		return err
+	}
+
+	if media == mediaJSON || media == mediaXML || media == mediaText {
+		w.Header().Set("Content-Type", media+";charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", media)
+	}
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// deprecated 为旧接口添加Deprecation和Link响应头，指向替代的接口
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}